@@ -0,0 +1,138 @@
+// Command margo provides tooling for margo sites.
+//
+// Usage:
+//
+//	margo fmt [-check] [path ...]
+//	margo export file.md
+//	margo import file.json
+//
+// fmt rewrites the margo blocks of the markdown files found at the given paths,
+// the current directory by default, in canonical form. Blocks it leaves as is are
+// reported: blocks holding comments, which the parser drops, and blocks it cannot
+// express. With -check the files are only listed and the command fails when one
+// of them is not formatted or has blocks it cannot express.
+//
+// export prints a markdown page as JSON, see margo.Page, and import prints
+// the markdown of a page exported as JSON.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/iota-uz/margo"
+	"github.com/iota-uz/margo/format"
+	"github.com/iota-uz/margo/parser"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	fmt.Fprintln(os.Stderr, "\tmargo fmt [-check] [path ...]")
+	fmt.Fprintln(os.Stderr, "\tmargo export file.md")
+	fmt.Fprintln(os.Stderr, "\tmargo import file.json")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "fmt":
+		os.Exit(runFmt(os.Args[2:]))
+	case "export":
+		os.Exit(runExport(os.Args[2:]))
+	case "import":
+		os.Exit(runImport(os.Args[2:]))
+	default:
+		usage()
+	}
+}
+
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "list unformatted files without rewriting them")
+	_ = flags.Parse(args)
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, skipped, err := format.Files(paths, *check)
+	for _, file := range files {
+		fmt.Println(file)
+	}
+	failed := false
+	for _, block := range skipped {
+		fmt.Fprintln(os.Stderr, block)
+		failed = failed || !block.Comments
+	}
+	if err != nil {
+		errs := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+		for _, err := range errs {
+			var diags parser.Diagnostics
+			if errors.As(err, &diags) {
+				fmt.Fprintln(os.Stderr, diags.Pretty())
+			} else {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		return 1
+	}
+	if *check && (len(files) > 0 || failed) {
+		return 1
+	}
+	return 0
+}
+
+func runExport(args []string) int {
+	if len(args) != 1 {
+		usage()
+	}
+	source, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	page, err := margo.ExportPage(source, args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(page); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func runImport(args []string) int {
+	if len(args) != 1 {
+		usage()
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var page margo.Page
+	if err := json.Unmarshal(data, &page); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to decode %s: %v\n", args[0], err)
+		return 1
+	}
+	source, err := page.Source()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	_, _ = os.Stdout.Write(source)
+	return 0
+}
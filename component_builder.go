@@ -2,16 +2,31 @@ package margo
 
 import (
 	"context"
+	"encoding"
+	"errors"
 	"fmt"
 	"github.com/a-h/templ"
 	"github.com/iota-uz/margo/parser"
 	"github.com/iota-uz/margo/registry"
 	"github.com/yuin/goldmark/ast"
 	"io"
+	"math"
+	"net/url"
 	"reflect"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	contextType         = reflect.TypeOf((*context.Context)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	urlType             = reflect.TypeOf(url.URL{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 )
 
 type ValueSetter struct{}
@@ -21,6 +36,10 @@ func NewValueSetter() *ValueSetter {
 }
 
 func (vs *ValueSetter) SetTypedValue(field reflect.Value, value interface{}) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
 	switch field.Kind() {
 	case reflect.String:
 		switch v := value.(type) {
@@ -32,41 +51,160 @@ func (vs *ValueSetter) SetTypedValue(field reflect.Value, value interface{}) err
 			return fmt.Errorf("unsupported value type for string: %T", value)
 		}
 	case reflect.Bool:
-		field.Set(reflect.ValueOf(value))
+		return vs.setBoolValue(field, value)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return vs.setIntValue(field, value)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		return vs.setUintValue(field, value)
 	case reflect.Float32, reflect.Float64:
 		return vs.setFloatValue(field, value)
+	case reflect.Pointer:
+		elem := reflect.New(field.Type().Elem())
+		if err := vs.SetTypedValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
 	default:
 		return fmt.Errorf("unsupported field type: %s", field.Kind())
 	}
 	return nil
 }
 
+// SetParsedValue sets the types parsed from text: time.Duration, time.Time, url.URL
+// and the types implementing encoding.TextUnmarshaler, such as enums.
+// It reports whether field has one of these types.
+func (vs *ValueSetter) SetParsedValue(field reflect.Value, value interface{}) (bool, error) {
+	switch field.Type() {
+	case durationType:
+		s, ok := value.(string)
+		if !ok {
+			return true, fmt.Errorf("unsupported value type for duration: %T", value)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return true, fmt.Errorf("failed to parse duration: %w", err)
+		}
+		field.SetInt(int64(d))
+		return true, nil
+	case timeType:
+		s, ok := value.(string)
+		if !ok {
+			return true, fmt.Errorf("unsupported value type for time: %T", value)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			// dates are written without a time in front-matter
+			if t, err = time.Parse(time.DateOnly, s); err != nil {
+				return true, fmt.Errorf("failed to parse time %q, expected a date such as 2006-01-02 or RFC 3339", s)
+			}
+		}
+		field.Set(reflect.ValueOf(t))
+		return true, nil
+	case urlType:
+		s, ok := value.(string)
+		if !ok {
+			return true, fmt.Errorf("unsupported value type for url: %T", value)
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return true, fmt.Errorf("failed to parse url: %w", err)
+		}
+		field.Set(reflect.ValueOf(*u))
+		return true, nil
+	}
+	if !field.CanAddr() || !reflect.PointerTo(field.Type()).Implements(textUnmarshalerType) {
+		return false, nil
+	}
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case bool, int64, float64:
+		text = fmt.Sprint(v)
+	default:
+		return true, fmt.Errorf("unsupported value type for %s: %T", field.Type(), value)
+	}
+	return true, field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+}
+
+func (vs *ValueSetter) setBoolValue(field reflect.Value, value interface{}) error {
+	switch v := value.(type) {
+	case bool:
+		field.SetBool(v)
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse bool: %w", err)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported value type for bool: %T", value)
+	}
+	return nil
+}
+
 func (vs *ValueSetter) setIntValue(field reflect.Value, value interface{}) error {
-	i, err := strconv.ParseInt(value.(string), 10, 64)
-	if err != nil {
-		return fmt.Errorf("failed to parse int: %w", err)
+	var i int64
+	switch v := value.(type) {
+	case int64:
+		i = v
+	case float64:
+		if v != math.Trunc(v) {
+			return fmt.Errorf("failed to parse int: %v has a fraction", v)
+		}
+		i = int64(v)
+	case string:
+		var err error
+		if i, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return fmt.Errorf("failed to parse int: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported value type for int: %T", value)
+	}
+	if field.OverflowInt(i) {
+		return fmt.Errorf("%d overflows %s", i, field.Type())
 	}
 	field.SetInt(i)
 	return nil
 }
 
 func (vs *ValueSetter) setUintValue(field reflect.Value, value interface{}) error {
-	i, err := strconv.ParseUint(value.(string), 10, 64)
-	if err != nil {
-		return fmt.Errorf("failed to parse uint: %w", err)
+	var i uint64
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("%d overflows %s", v, field.Type())
+		}
+		i = uint64(v)
+	case string:
+		var err error
+		if i, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return fmt.Errorf("failed to parse uint: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported value type for uint: %T", value)
+	}
+	if field.OverflowUint(i) {
+		return fmt.Errorf("%d overflows %s", i, field.Type())
 	}
 	field.SetUint(i)
 	return nil
 }
 
 func (vs *ValueSetter) setFloatValue(field reflect.Value, value interface{}) error {
-	f, err := strconv.ParseFloat(value.(string), 64)
-	if err != nil {
-		return fmt.Errorf("failed to parse float: %w", err)
+	var f float64
+	switch v := value.(type) {
+	case float64:
+		f = v
+	case int64:
+		f = float64(v)
+	case string:
+		var err error
+		if f, err = strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("failed to parse float: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported value type for float: %T", value)
 	}
 	field.SetFloat(f)
 	return nil
@@ -84,95 +222,425 @@ func NewComponentBuilder(reg registry.Layout) *ComponentBuilder {
 	}
 }
 
-func (cb *ComponentBuilder) Build(component any, attrs []ast.Attribute) (templ.Component, error) {
+// Build calls the component with props built from attrs, and ctx when it takes a context.
+// Expressions in the attributes are resolved against the page context of ctx.
+// The error returned by the component, if any, is returned as is.
+func (cb *ComponentBuilder) Build(ctx context.Context, component any, attrs []ast.Attribute) (templ.Component, error) {
 	reflectV := reflect.ValueOf(component)
-	props, err := cb.buildProps(reflectV, attrs)
+	if reflectV.Kind() != reflect.Func {
+		return nil, fmt.Errorf("unsupported component type %T, expected a function", component)
+	}
+	args, err := cb.buildProps(ctx, reflectV, attrs)
 	if err != nil {
 		return nil, err
 	}
-	return reflectV.Call(props)[0].Interface().(templ.Component), nil
+	if takesContext(reflectV.Type()) {
+		args = append([]reflect.Value{reflect.ValueOf(&ctx).Elem()}, args...)
+	}
+	out := reflectV.Call(args)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	result, ok := out[0].Interface().(templ.Component)
+	if !ok || result == nil {
+		return nil, errors.New("component returned no templ.Component")
+	}
+	return result, nil
 }
 
-func (cb *ComponentBuilder) buildProps(componentFunc reflect.Value, attrs []ast.Attribute) ([]reflect.Value, error) {
-	if componentFunc.Type().NumIn() == 0 {
+// takesContext reports whether the component function of type t takes a context.Context first.
+func takesContext(t reflect.Type) bool {
+	return t.NumIn() > 0 && t.In(0) == contextType
+}
+
+func (cb *ComponentBuilder) buildProps(ctx context.Context, componentFunc reflect.Value, attrs []ast.Attribute) ([]reflect.Value, error) {
+	in := 0
+	if takesContext(componentFunc.Type()) {
+		in++
+	}
+	if componentFunc.Type().NumIn() == in {
 		return []reflect.Value{}, nil
 	}
 
-	propsType := componentFunc.Type().In(0)
+	propsType := componentFunc.Type().In(in)
+	if propsType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported props type %s, expected a struct", propsType)
+	}
+	plan, err := propsPlanOf(propsType)
+	if err != nil {
+		return nil, err
+	}
 	props := reflect.New(propsType).Elem()
-	var used []string
-	for i := 0; i < propsType.NumField(); i++ {
-		field := props.Field(i)
-		if !field.IsValid() || !field.CanSet() {
+	used := make([]bool, len(attrs))
+	set := make([]bool, len(plan.fields))
+	unused := 0
+	for i, attr := range attrs {
+		fi, ok := plan.byName[normalizePropName(string(attr.Name))]
+		if !ok {
+			unused++
 			continue
 		}
-		for _, attr := range attrs {
-			k := string(attr.Name)
-			if strings.EqualFold(k, propsType.Field(i).Name) {
-				used = append(used, k)
-				if field.Kind() == reflect.Interface {
-					if err := cb.setInterfaceValue(field, attr.Value); err != nil {
-						return nil, err
-					}
-				} else {
-					if err := cb.valSetter.SetTypedValue(field, attr.Value); err != nil {
-						return nil, err
-					}
+		used[i] = true
+		pf := &plan.fields[fi]
+		field := props.Field(pf.index)
+		// null and expressions resolving to nil leave the prop unset
+		value, err := resolveValue(ctx, attr.Value)
+		if err == nil && value != nil {
+			set[fi] = true
+			err = cb.bindValue(ctx, field, value)
+		}
+		if err != nil {
+			return nil, &PropError{
+				Component: currentComponent(ctx),
+				Prop:      pf.name,
+				Type:      field.Type(),
+				Err:       err,
+			}
+		}
+	}
+
+	var missing []string
+	for fi, pf := range plan.fields {
+		if set[fi] {
+			continue
+		}
+		if pf.def != nil {
+			field := props.Field(pf.index)
+			if err := cb.setValue(ctx, field, *pf.def); err != nil {
+				return nil, &PropError{
+					Component: currentComponent(ctx),
+					Prop:      pf.name,
+					Type:      field.Type(),
+					Err:       fmt.Errorf("default: %w", err),
 				}
 			}
+		} else if pf.required {
+			missing = append(missing, pf.name)
 		}
 	}
 
-	componentAttrs := templ.Attributes{}
-	for _, attr := range attrs {
-		if !slices.Contains(used, string(attr.Name)) {
+	if unused > 0 && plan.attrs >= 0 {
+		componentAttrs := templ.Attributes{}
+		for i, attr := range attrs {
+			if used[i] {
+				continue
+			}
 			key := string(attr.Name)
-			if v, ok := attr.Value.(string); ok {
+			value, err := resolveValue(ctx, attr.Value)
+			if err != nil {
+				return nil, err
+			}
+			switch v := value.(type) {
+			case nil:
+				// null leaves the attribute unset
+			case string, bool:
 				componentAttrs[key] = v
-			} else {
-				componentAttrs[key] = string(attr.Value.([]byte))
+			case []byte:
+				componentAttrs[key] = string(v)
+			default:
+				componentAttrs[key] = fmt.Sprint(v)
 			}
 		}
+		props.Field(plan.attrs).Set(reflect.ValueOf(componentAttrs))
+	} else if unused > 0 {
+		return nil, newUnknownPropError(currentComponent(ctx), propsType, plan.fields, attrs, used)
+	}
+	if len(missing) > 0 {
+		return nil, &MissingPropsError{Component: currentComponent(ctx), Props: missing}
 	}
 
-	for i := 0; i < propsType.NumField(); i++ {
-		field := props.Field(i)
-		if !field.IsValid() || !field.CanSet() {
+	return []reflect.Value{props}, nil
+}
+
+// newUnknownPropError reports the attrs not used along with the closest props of fields.
+func newUnknownPropError(component string, propsType reflect.Type, fields []propField, attrs []ast.Attribute, used []bool) *UnknownPropError {
+	names := make([]string, 0, len(fields))
+	err := &UnknownPropError{Component: component, Suggestions: make(map[string][]string)}
+	for _, pf := range fields {
+		names = append(names, pf.name)
+		err.Valid = append(err.Valid, pf.name+" "+propsType.Field(pf.index).Type.String())
+	}
+	for i, attr := range attrs {
+		name := string(attr.Name)
+		if used[i] || slices.Contains(err.Props, name) {
 			continue
 		}
-		if field.Kind() == reflect.Map && field.Type().String() == "templ.Attributes" {
-			field.Set(reflect.ValueOf(componentAttrs))
-			for k := range componentAttrs {
-				used = append(used, k)
+		err.Props = append(err.Props, name)
+		if suggestions := suggest(name, names, normalizePropName); len(suggestions) > 0 {
+			err.Suggestions[name] = suggestions
+		}
+	}
+	return err
+}
+
+// propField describes how a field of a props struct binds to props,
+// following its margo tag.
+//
+//	Href    string `margo:"href,required,alias=url"`
+//	Variant variant `margo:",default=primary"`
+//	Cache   bool    `margo:"-"`
+type propField struct {
+	index int
+	// name is the name of the prop, the field name unless the tag sets one.
+	name string
+	// names are the normalized names matching the prop, see normalizePropName.
+	names    []string
+	required bool
+	// def is the value of the prop when it is not set or null.
+	def *string
+}
+
+// propsPlan is the binding plan of a props struct, computed once per type
+// as reflecting the struct on every render is costly.
+type propsPlan struct {
+	fields []propField
+	// byName maps the normalized names of the props to their field in fields.
+	byName map[string]int
+	// attrs is the index of the templ.Attributes field receiving the unknown props, -1 if none.
+	attrs int
+}
+
+var (
+	attributesType = reflect.TypeOf(templ.Attributes{})
+	// propsPlans caches the *propsPlan of the props types.
+	propsPlans sync.Map
+)
+
+// propsPlanOf returns the binding plan of the struct t.
+func propsPlanOf(t reflect.Type) (*propsPlan, error) {
+	if plan, ok := propsPlans.Load(t); ok {
+		return plan.(*propsPlan), nil
+	}
+	fields, err := propFields(t)
+	if err != nil {
+		return nil, err
+	}
+	plan := &propsPlan{
+		fields: fields,
+		byName: make(map[string]int, len(fields)),
+		attrs:  -1,
+	}
+	for i, pf := range fields {
+		for _, name := range pf.names {
+			if other, ok := plan.byName[name]; ok {
+				return nil, fmt.Errorf("prop %s of %s conflicts with %s", pf.name, t, fields[other].name)
 			}
+			plan.byName[name] = i
 		}
 	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() && t.Field(i).Type == attributesType {
+			plan.attrs = i
+		}
+	}
+	actual, _ := propsPlans.LoadOrStore(t, plan)
+	return actual.(*propsPlan), nil
+}
 
-	if len(used) < len(attrs) {
-		var errorMessage strings.Builder
-		errorMessage.WriteString("unknown prop: ")
-		for _, attr := range attrs {
-			if !slices.Contains(used, string(attr.Name)) {
-				errorMessage.WriteString(string(attr.Name))
-				errorMessage.WriteString(" ")
+// propFields returns the exported fields of the struct t that bind to props.
+func propFields(t reflect.Type) ([]propField, error) {
+	var fields []propField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		pf := propField{index: i, name: sf.Name}
+		tag := strings.Split(sf.Tag.Get("margo"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		if tag[0] != "" {
+			pf.name = tag[0]
+		}
+		pf.names = []string{normalizePropName(pf.name)}
+		for _, option := range tag[1:] {
+			key, value, ok := strings.Cut(option, "=")
+			switch {
+			case option == "required":
+				pf.required = true
+			case key == "default" && ok:
+				pf.def = &value
+			case key == "alias" && value != "":
+				pf.names = append(pf.names, normalizePropName(value))
+			default:
+				return nil, fmt.Errorf("invalid margo tag of %s.%s: unknown option %q", t, sf.Name, option)
 			}
 		}
-		return nil, fmt.Errorf(errorMessage.String())
+		fields = append(fields, pf)
 	}
+	return fields, nil
+}
 
-	return []reflect.Value{props}, nil
+// normalizePropName folds the case of name and drops dashes and underscores,
+// so that "button-text" and "button_text" match ButtonText.
+func normalizePropName(name string) string {
+	normalized := true
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c == '-' || c == '_' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf {
+			normalized = false
+			break
+		}
+	}
+	if normalized {
+		return name
+	}
+	b := make([]byte, 0, len(name))
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c == '-' || c == '_':
+		case c >= 'A' && c <= 'Z':
+			b = append(b, c+'a'-'A')
+		default:
+			b = append(b, c)
+		}
+	}
+	// non-ASCII letters are folded by strings.ToLower, which is a no-op for ASCII ones
+	return strings.ToLower(string(b))
+}
+
+// setValue assigns a property value to field, binding lists to slices
+// and objects to maps and structs.
+func (cb *ComponentBuilder) setValue(ctx context.Context, field reflect.Value, value interface{}) error {
+	value, err := resolveValue(ctx, value)
+	if err != nil {
+		return err
+	}
+	return cb.bindValue(ctx, field, value)
+}
+
+// bindValue assigns a resolved property value to field, see setValue.
+func (cb *ComponentBuilder) bindValue(ctx context.Context, field reflect.Value, value interface{}) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	if ok, err := cb.valSetter.SetParsedValue(field, value); ok {
+		return err
+	}
+	switch field.Kind() {
+	case reflect.Interface:
+		return cb.setInterfaceValue(ctx, field, value)
+	case reflect.Pointer:
+		elem := reflect.New(field.Type().Elem())
+		if err := cb.setValue(ctx, elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	case reflect.Slice:
+		return cb.setSliceValue(ctx, field, value)
+	case reflect.Map:
+		return cb.setMapValue(ctx, field, value)
+	case reflect.Struct:
+		return cb.setStructValue(ctx, field, value)
+	default:
+		// do nothing
+	}
+	return cb.valSetter.SetTypedValue(field, value)
+}
+
+func (cb *ComponentBuilder) setSliceValue(ctx context.Context, field reflect.Value, value interface{}) error {
+	list, ok := value.(parser.List)
+	if !ok {
+		// a single value is a list of one item
+		list = parser.List{value}
+	}
+	slice := reflect.MakeSlice(field.Type(), len(list), len(list))
+	for i, item := range list {
+		if err := cb.setValue(ctx, slice.Index(i), item); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	field.Set(slice)
+	return nil
+}
+
+func (cb *ComponentBuilder) setMapValue(ctx context.Context, field reflect.Value, value interface{}) error {
+	object, ok := value.(parser.Object)
+	if !ok {
+		return fmt.Errorf("unsupported value type for map: %T", value)
+	}
+	if field.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("unsupported map key type: %s", field.Type().Key())
+	}
+	m := reflect.MakeMapWithSize(field.Type(), len(object))
+	for _, attr := range object {
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if err := cb.setValue(ctx, elem, attr.Value); err != nil {
+			return fmt.Errorf("key %s: %w", attr.Name, err)
+		}
+		m.SetMapIndex(reflect.ValueOf(attr.Name).Convert(field.Type().Key()), elem)
+	}
+	field.Set(m)
+	return nil
 }
 
-func (cb *ComponentBuilder) setInterfaceValue(field reflect.Value, value interface{}) error {
+func (cb *ComponentBuilder) setStructValue(ctx context.Context, field reflect.Value, value interface{}) error {
+	object, ok := value.(parser.Object)
+	if !ok {
+		return fmt.Errorf("unsupported value type for struct: %T", value)
+	}
+	structType := field.Type()
+	plan, err := propsPlanOf(structType)
+	if err != nil {
+		return err
+	}
+	set := make([]bool, len(plan.fields))
+	for _, attr := range object {
+		fi, ok := plan.byName[normalizePropName(attr.Name)]
+		if !ok {
+			names := make([]string, 0, len(plan.fields))
+			for _, pf := range plan.fields {
+				names = append(names, pf.name)
+			}
+			if hint := didYouMean(suggest(attr.Name, names, normalizePropName)); hint != "" {
+				return fmt.Errorf("unknown field %s of %s, %s", attr.Name, structType, hint)
+			}
+			return fmt.Errorf("unknown field %s of %s", attr.Name, structType)
+		}
+		// as for props, null and expressions resolving to nil leave the field unset
+		value, err := resolveValue(ctx, attr.Value)
+		if err == nil && value != nil {
+			set[fi] = true
+			err = cb.bindValue(ctx, field.Field(plan.fields[fi].index), value)
+		}
+		if err != nil {
+			return fmt.Errorf("field %s: %w", attr.Name, err)
+		}
+	}
+	var missing []string
+	for fi, pf := range plan.fields {
+		if set[fi] {
+			continue
+		}
+		if pf.def != nil {
+			if err := cb.setValue(ctx, field.Field(pf.index), *pf.def); err != nil {
+				return fmt.Errorf("field %s: default: %w", pf.name, err)
+			}
+		} else if pf.required {
+			missing = append(missing, pf.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required fields of %s: %s", structType, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (cb *ComponentBuilder) setInterfaceValue(ctx context.Context, field reflect.Value, value interface{}) error {
 	if field.Type().String() != "templ.Component" {
 		return fmt.Errorf("unsupported interface type: %s", field.Type())
 	}
 
 	switch v := value.(type) {
+	case nil:
+		field.Set(reflect.Zero(field.Type()))
+		return nil
 	case *parser.ComponentNode:
-		return cb.setComponentNodeValue(field, v)
+		return cb.setComponentNodeValue(ctx, field, v)
 	case *parser.TextNode:
-		return cb.setTextNodeValue(field, v)
+		return cb.setTextNodeValue(ctx, field, v)
 	case string:
 		return cb.setStringValue(field, v)
 	default:
@@ -180,12 +648,13 @@ func (cb *ComponentBuilder) setInterfaceValue(field reflect.Value, value interfa
 	}
 }
 
-func (cb *ComponentBuilder) setComponentNodeValue(field reflect.Value, node *parser.ComponentNode) error {
+func (cb *ComponentBuilder) setComponentNodeValue(ctx context.Context, field reflect.Value, node *parser.ComponentNode) error {
+	ctx = withComponent(ctx, node.Name)
 	cmpFunc, err := cb.GetComponent(node.Name, nil)
 	if err != nil {
 		return err
 	}
-	cmp, err := cb.Build(cmpFunc, node.Attributes())
+	cmp, err := cb.Build(ctx, cmpFunc, node.Attributes())
 	if err != nil {
 		return fmt.Errorf("failed to build component %s: %w", node.Name, err)
 	}
@@ -193,11 +662,12 @@ func (cb *ComponentBuilder) setComponentNodeValue(field reflect.Value, node *par
 	return nil
 }
 
-func (cb *ComponentBuilder) setTextNodeValue(field reflect.Value, node *parser.TextNode) error {
-	field.Set(reflect.ValueOf(templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
-		return New(cb.layout).Convert([]byte(node.Value), w)
-	})))
-	return nil
+func (cb *ComponentBuilder) setTextNodeValue(ctx context.Context, field reflect.Value, node *parser.TextNode) error {
+	value, err := interpolate(ctx, node.Value)
+	if err != nil {
+		return err
+	}
+	return cb.setStringValue(field, value)
 }
 
 func (cb *ComponentBuilder) setStringValue(field reflect.Value, value string) error {
@@ -216,8 +686,16 @@ func (cb *ComponentBuilder) GetComponent(name string, ns registry.Layout) (any,
 	if c, ok := cb.layout.Get(name); ok {
 		return c, nil
 	}
+	candidates := cb.layout.List()
+	if ns != nil {
+		candidates = append(ns.List(), candidates...)
+	}
+	hint := didYouMean(suggest(name, candidates, nil))
+	if hint != "" {
+		hint = ", " + hint
+	}
 	if ns != nil {
-		return nil, fmt.Errorf("component %s not found in namespace: %s", name, ns.Name())
+		return nil, fmt.Errorf("component %s not found in namespace: %s%s", name, ns.Name(), hint)
 	}
-	return nil, fmt.Errorf("component %s not found in layout %s", name, cb.layout.Name())
+	return nil, fmt.Errorf("component %s not found in layout %s%s", name, cb.layout.Name(), hint)
 }
@@ -0,0 +1,622 @@
+package margo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/iota-uz/margo/parser"
+	"github.com/iota-uz/margo/registry"
+	"github.com/iota-uz/margo/types"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type galleryProps struct {
+	Count   int
+	Ratio   float32
+	Visible bool
+	Limit   *int
+	Image   *string
+	Attrs   templ.Attributes
+}
+
+func gallery(props galleryProps) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := fmt.Fprintf(w, "%d %v %v %d %v", props.Count, props.Ratio, props.Visible, *props.Limit, props.Image)
+		return err
+	})
+}
+
+func TestComponentBuilderLiterals(t *testing.T) {
+	input := `\Gallery
+    Count: 3
+    Ratio: 0.75
+    Visible: false
+    Limit: 10
+    Image: null`
+
+	nodes, err := parser.NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	cb := NewComponentBuilder(registry.NewLayout("test"))
+	cmp, err := cb.Build(context.Background(), gallery, nodes[0].(*parser.ComponentNode).Attributes())
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := cmp.Render(context.Background(), &buf); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if expected := "3 0.75 false 10 <nil>"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestComponentBuilderLiteralErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "fraction into int", input: "\\Gallery\n    Count: 1.5"},
+		{name: "number into bool", input: "\\Gallery\n    Visible: 1"},
+	}
+	cb := NewComponentBuilder(registry.NewLayout("test"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := parser.NewMargoParser(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+			if _, err := cb.Build(context.Background(), gallery, nodes[0].(*parser.ComponentNode).Attributes()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+type pricingProps struct {
+	Features []string
+	Prices   map[string]float64
+	Plan     struct {
+		Name  string
+		Limit *int
+	}
+	Plans   []*struct{ Name string }
+	Buttons []templ.Component
+}
+
+func TestComponentBuilderListsAndObjects(t *testing.T) {
+	input := `\Pricing
+    Features:
+        - "Fast"
+        - "Secure"
+    Prices:
+        Monthly: 9
+        Yearly: 99.5
+    Plan:
+        Name: "Pro"
+        Limit: 3
+    Plans:
+        - Name: "Free"
+        - Name: "Team"
+    Buttons:
+        \Button
+        \Button`
+
+	nodes, err := parser.NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	layout := registry.NewLayout("test")
+	layout.Register("Button", func() templ.Component {
+		return templ.Raw("<button></button>")
+	})
+	var props pricingProps
+	pricing := func(p pricingProps) templ.Component {
+		props = p
+		return templ.NopComponent
+	}
+	if _, err := NewComponentBuilder(layout).Build(context.Background(), pricing, nodes[0].(*parser.ComponentNode).Attributes()); err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	if len(props.Features) != 2 || props.Features[1] != "Secure" {
+		t.Errorf("unexpected features %v", props.Features)
+	}
+	if props.Prices["Monthly"] != 9 || props.Prices["Yearly"] != 99.5 {
+		t.Errorf("unexpected prices %v", props.Prices)
+	}
+	if props.Plan.Name != "Pro" || props.Plan.Limit == nil || *props.Plan.Limit != 3 {
+		t.Errorf("unexpected plan %+v", props.Plan)
+	}
+	if len(props.Plans) != 2 || props.Plans[1].Name != "Team" {
+		t.Errorf("unexpected plans %v", props.Plans)
+	}
+	if len(props.Buttons) != 2 {
+		t.Fatalf("expected 2 buttons, got %d", len(props.Buttons))
+	}
+	var buf bytes.Buffer
+	if err := props.Buttons[0].Render(context.Background(), &buf); err != nil || buf.String() != "<button></button>" {
+		t.Errorf("unexpected button %q: %v", buf.String(), err)
+	}
+}
+
+func TestComponentBuilderUnknownField(t *testing.T) {
+	input := `\Pricing
+    Plan:
+        Title: "Pro"`
+
+	nodes, err := parser.NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	pricing := func(p pricingProps) templ.Component { return templ.NopComponent }
+	_, err = NewComponentBuilder(registry.NewLayout("test")).Build(context.Background(), pricing, nodes[0].(*parser.ComponentNode).Attributes())
+	if err == nil || !strings.Contains(err.Error(), "unknown field Title") {
+		t.Errorf("expected an unknown field error, got %v", err)
+	}
+}
+
+func TestComponentBuilderExpressions(t *testing.T) {
+	input := `\Gallery
+    Count: {{ page.count }}
+    Ratio: {{ site.ratio }}
+    Limit: 1
+    Title: "{{ page.title }} on {{ site.name }}"`
+
+	nodes, err := parser.NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	ctx := types.WithPageCtx(context.Background(), &types.PageContext{
+		Meta: map[string]any{"count": 3, "title": "Home"},
+		Site: map[string]any{"name": "Margo", "ratio": 0.5},
+	})
+	var props galleryProps
+	capture := func(p galleryProps) templ.Component {
+		props = p
+		return templ.NopComponent
+	}
+	cb := NewComponentBuilder(registry.NewLayout("test"))
+	if _, err := cb.Build(ctx, capture, nodes[0].(*parser.ComponentNode).Attributes()); err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	if props.Count != 3 || props.Ratio != 0.5 || props.Attrs["Title"] != "Home on Margo" {
+		t.Errorf("unexpected props %+v", props)
+	}
+
+	nodes, _ = parser.NewMargoParser("\\Gallery\n    Count: {{ page.total }}").Parse()
+	_, err = cb.Build(ctx, capture, nodes[0].(*parser.ComponentNode).Attributes())
+	if err == nil || !strings.Contains(err.Error(), "unknown variable page.total") {
+		t.Errorf("expected an unknown variable error, got %v", err)
+	}
+}
+
+type variant string
+
+func (v *variant) UnmarshalText(text []byte) error {
+	switch s := variant(text); s {
+	case "primary", "secondary":
+		*v = s
+		return nil
+	default:
+		return fmt.Errorf("unknown variant %q", text)
+	}
+}
+
+type eventProps struct {
+	Date     time.Time
+	Starts   *time.Time
+	Duration time.Duration
+	Link     url.URL
+	Mirror   *url.URL
+	Variant  variant
+	Variants []variant
+}
+
+func TestComponentBuilderParsedTypes(t *testing.T) {
+	input := `\Event
+    Date: "2024-05-01"
+    Starts: "2024-05-01T09:30:00Z"
+    Duration: "1h30m"
+    Link: "https://example.com/events?id=1"
+    Mirror: "https://mirror.example.com"
+    Variant: "primary"
+    Variants:
+        - "primary"
+        - "secondary"`
+
+	nodes, err := parser.NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	var props eventProps
+	event := func(p eventProps) templ.Component {
+		props = p
+		return templ.NopComponent
+	}
+	cb := NewComponentBuilder(registry.NewLayout("test"))
+	if _, err := cb.Build(context.Background(), event, nodes[0].(*parser.ComponentNode).Attributes()); err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	if !props.Date.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected date %v", props.Date)
+	}
+	if props.Starts == nil || props.Starts.Hour() != 9 || props.Starts.Minute() != 30 {
+		t.Errorf("unexpected start %v", props.Starts)
+	}
+	if props.Duration != 90*time.Minute {
+		t.Errorf("unexpected duration %v", props.Duration)
+	}
+	if props.Link.Host != "example.com" || props.Link.Query().Get("id") != "1" {
+		t.Errorf("unexpected link %v", props.Link)
+	}
+	if props.Mirror == nil || props.Mirror.Host != "mirror.example.com" {
+		t.Errorf("unexpected mirror %v", props.Mirror)
+	}
+	if props.Variant != "primary" || len(props.Variants) != 2 || props.Variants[1] != "secondary" {
+		t.Errorf("unexpected variants %v %v", props.Variant, props.Variants)
+	}
+}
+
+func TestComponentBuilderPropError(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		prop     string
+		expected string
+	}{
+		{
+			name:     "invalid date",
+			input:    "\\Event\n    Date: \"soon\"",
+			prop:     "Date",
+			expected: `prop Date of Event, expected time.Time: failed to parse time "soon", expected a date such as 2006-01-02 or RFC 3339`,
+		},
+		{
+			name:     "invalid duration",
+			input:    "\\Event\n    Duration: 90",
+			prop:     "Duration",
+			expected: "prop Duration of Event, expected time.Duration: unsupported value type for duration: int64",
+		},
+		{
+			name:     "invalid enum",
+			input:    "\\Event\n    Variants:\n        - \"primary\"\n        - \"danger\"",
+			prop:     "Variants",
+			expected: `prop Variants of Event, expected []margo.variant: item 1: unknown variant "danger"`,
+		},
+	}
+	event := func(p eventProps) templ.Component { return templ.NopComponent }
+	cb := NewComponentBuilder(registry.NewLayout("test"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := parser.NewMargoParser(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+			_, err = cb.Build(withComponent(context.Background(), "Event"), event, nodes[0].(*parser.ComponentNode).Attributes())
+			var propErr *PropError
+			if !errors.As(err, &propErr) {
+				t.Fatalf("expected a *PropError, got %v", err)
+			}
+			if propErr.Component != "Event" || propErr.Prop != tt.prop {
+				t.Errorf("expected prop %s of Event, got %s of %s", tt.prop, propErr.Prop, propErr.Component)
+			}
+			if err.Error() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, err.Error())
+			}
+		})
+	}
+}
+
+type buttonProps struct {
+	Href       string  `margo:",required,alias=url"`
+	ButtonText string  `margo:",required"`
+	Variant    variant `margo:",default=primary"`
+	Size       int     `margo:"scale,default=2"`
+	Secret     string  `margo:"-"`
+}
+
+func TestComponentBuilderTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected buttonProps
+		err      string
+	}{
+		{
+			name:     "defaults and kebab case",
+			input:    "\\Button\n    url: \"/signup\"\n    button-text: \"Sign up\"",
+			expected: buttonProps{Href: "/signup", ButtonText: "Sign up", Variant: "primary", Size: 2},
+		},
+		{
+			name:     "snake case and tag name",
+			input:    "\\Button\n    Href: \"/\"\n    button_text: \"Home\"\n    Variant: \"secondary\"\n    Scale: 3",
+			expected: buttonProps{Href: "/", ButtonText: "Home", Variant: "secondary", Size: 3},
+		},
+		{
+			name:  "missing required props",
+			input: "\\Button\n    Variant: \"secondary\"",
+			err:   "missing required props of Button: Href, ButtonText",
+		},
+		{
+			name:  "null required prop",
+			input: "\\Button\n    Href: null\n    ButtonText: \"Home\"",
+			err:   "missing required props of Button: Href",
+		},
+		{
+			name:     "nil expression default",
+			input:    "\\Button\n    Href: \"/\"\n    ButtonText: \"Home\"\n    Variant: {{ page.variant }}",
+			expected: buttonProps{Href: "/", ButtonText: "Home", Variant: "primary", Size: 2},
+		},
+		{
+			name:  "nil expression required prop",
+			input: "\\Button\n    Href: {{ page.href }}\n    ButtonText: \"Home\"",
+			err:   "missing required props of Button: Href",
+		},
+		{
+			name:  "ignored field",
+			input: "\\Button\n    Href: \"/\"\n    ButtonText: \"Home\"\n    Secret: \"x\"",
+			err:   "unknown prop of Button: Secret; valid props: Href string, ButtonText string, Variant margo.variant, scale int",
+		},
+	}
+	var props buttonProps
+	button := func(p buttonProps) templ.Component {
+		props = p
+		return templ.NopComponent
+	}
+	cb := NewComponentBuilder(registry.NewLayout("test"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := parser.NewMargoParser(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+			props = buttonProps{}
+			ctx := types.WithPageCtx(withComponent(context.Background(), "Button"), &types.PageContext{
+				Meta: map[string]any{"href": nil, "variant": nil},
+			})
+			_, err = cb.Build(ctx, button, nodes[0].(*parser.ComponentNode).Attributes())
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build() failed: %v", err)
+			}
+			if props != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, props)
+			}
+		})
+	}
+
+	invalid := func(p struct {
+		Href string `margo:",requried"`
+	}) templ.Component {
+		return templ.NopComponent
+	}
+	if _, err := cb.Build(context.Background(), invalid, nil); err == nil || !strings.Contains(err.Error(), `unknown option "requried"`) {
+		t.Errorf("expected an invalid tag error, got %v", err)
+	}
+}
+
+type authorProps struct {
+	Name  string `margo:",default=anon"`
+	Email string `margo:",required"`
+}
+
+func TestComponentBuilderNestedTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected authorProps
+		err      string
+	}{
+		{
+			name:     "nil expression default",
+			input:    "\\Post\n    Author:\n        Name: {{ page.missing }}\n        Email: \"a@example.com\"",
+			expected: authorProps{Name: "anon", Email: "a@example.com"},
+		},
+		{
+			name:  "nil expression required field",
+			input: "\\Post\n    Author:\n        Name: \"Ann\"\n        Email: {{ page.missing }}",
+			err:   "missing required fields of margo.authorProps: Email",
+		},
+	}
+	var author authorProps
+	post := func(p struct{ Author authorProps }) templ.Component {
+		author = p.Author
+		return templ.NopComponent
+	}
+	cb := NewComponentBuilder(registry.NewLayout("test"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := parser.NewMargoParser(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+			author = authorProps{}
+			ctx := types.WithPageCtx(withComponent(context.Background(), "Post"), &types.PageContext{
+				Meta: map[string]any{"missing": nil},
+			})
+			_, err = cb.Build(ctx, post, nodes[0].(*parser.ComponentNode).Attributes())
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build() failed: %v", err)
+			}
+			if author != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, author)
+			}
+		})
+	}
+}
+
+func TestComponentBuilderSuggestions(t *testing.T) {
+	layout := registry.NewLayout("blog")
+	layout.Register("ButtonPrimary", func() templ.Component { return templ.NopComponent })
+	layout.Register("ButtonSecondary", func() templ.Component { return templ.NopComponent })
+	layout.Register("Hero", func() templ.Component { return templ.NopComponent })
+	cb := NewComponentBuilder(layout)
+
+	_, err := cb.GetComponent("ButonPrimary", nil)
+	if expected := "component ButonPrimary not found in layout blog, did you mean ButtonPrimary?"; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+	_, err = cb.GetComponent("Carousel", nil)
+	if expected := "component Carousel not found in layout blog"; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+
+	nodes, err := parser.NewMargoParser("\\Button\n    Titel: \"Home\"\n    Hre: \"/\"\n    Color: \"red\"").Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	button := func(p struct {
+		Title string
+		Href  string
+	}) templ.Component {
+		return templ.NopComponent
+	}
+	_, err = cb.Build(withComponent(context.Background(), "Button"), button, nodes[0].(*parser.ComponentNode).Attributes())
+	var propErr *UnknownPropError
+	if !errors.As(err, &propErr) {
+		t.Fatalf("expected an *UnknownPropError, got %v", err)
+	}
+	expected := "unknown props of Button: Titel (did you mean Title?), Hre (did you mean Href?), Color; valid props: Title string, Href string"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestComponentBuilderSignatures(t *testing.T) {
+	type key struct{}
+	errNotFound := errors.New("post not found")
+	nodes, err := parser.NewMargoParser("\\Post Slug: \"hello\"").Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	attrs := nodes[0].(*parser.ComponentNode).Attributes()
+	type postProps struct{ Slug string }
+
+	tests := map[string]struct {
+		component any
+		err       error
+	}{
+		"props": {component: func(p postProps) templ.Component { return templ.Raw(p.Slug) }},
+		"props and error": {component: func(p postProps) (templ.Component, error) {
+			return templ.Raw(p.Slug), nil
+		}},
+		"context and props": {component: func(ctx context.Context, p postProps) (templ.Component, error) {
+			return templ.Raw(ctx.Value(key{}).(string) + p.Slug), nil
+		}},
+		"context only": {component: func(ctx context.Context) templ.Component {
+			return templ.Raw(ctx.Value(key{}).(string))
+		}},
+		"returned error": {component: func(ctx context.Context, p postProps) (templ.Component, error) {
+			return nil, errNotFound
+		}, err: errNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			layout := registry.NewLayout("test")
+			layout.Register("Post", tt.component)
+			cb := NewComponentBuilder(layout)
+			ctx := context.WithValue(context.Background(), key{}, "/")
+			component, err := cb.Build(ctx, tt.component, attrs)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			var buf bytes.Buffer
+			if err := component.Render(ctx, &buf); err != nil {
+				t.Fatalf("Render() failed: %v", err)
+			}
+			if got := buf.String(); got != "hello" && got != "/hello" && got != "/" {
+				t.Errorf("unexpected output %q", got)
+			}
+		})
+	}
+
+	_, err = NewComponentBuilder(registry.NewLayout("test")).Build(context.Background(), func(postProps) (templ.Component, error) {
+		return nil, nil
+	}, attrs)
+	if err == nil {
+		t.Error("expected an error for a nil component")
+	}
+}
+
+func TestRegisterSignatures(t *testing.T) {
+	for name, component := range map[string]any{
+		"two structs":     func(struct{}, struct{}) templ.Component { return nil },
+		"context last":    func(struct{}, context.Context) templ.Component { return nil },
+		"no results":      func(struct{}) {},
+		"non error":       func(struct{}) (templ.Component, bool) { return nil, false },
+		"three results":   func(struct{}) (templ.Component, error, error) { return nil, nil, nil },
+		"not a function":  "Post",
+		"string argument": func(string) templ.Component { return nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected Register to panic")
+				}
+			}()
+			registry.NewLayout("test").Register("Post", component)
+		})
+	}
+}
+
+// BenchmarkComponentBuilderPage builds the components of a page of 300 cards,
+// with binding plans cached as when serving pages and computed on every build.
+func BenchmarkComponentBuilderPage(b *testing.B) {
+	var input strings.Builder
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&input, "\\Card\n    Title: \"Card %d\"\n    Href: \"/cards/%d\"\n    Count: %d\n    Visible: true\n    Variant: \"primary\"\n", i, i, i)
+	}
+	nodes, err := parser.NewMargoParser(input.String()).Parse()
+	if err != nil {
+		b.Fatalf("Parse() failed: %v", err)
+	}
+	card := func(p struct {
+		Title   string `margo:",required"`
+		Href    string `margo:",alias=url"`
+		Count   int
+		Visible bool
+		Variant variant `margo:",default=secondary"`
+		Image   *string
+		Tags    []string
+	}) templ.Component {
+		return templ.NopComponent
+	}
+	cb := NewComponentBuilder(registry.NewLayout("test"))
+	ctx := context.Background()
+	build := func(b *testing.B, clear bool) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, node := range nodes {
+				if clear {
+					propsPlans.Clear()
+				}
+				if _, err := cb.Build(ctx, card, node.(*parser.ComponentNode).Attributes()); err != nil {
+					b.Fatalf("Build() failed: %v", err)
+				}
+			}
+		}
+	}
+	b.Run("cached", func(b *testing.B) { build(b, false) })
+	b.Run("uncached", func(b *testing.B) { build(b, true) })
+}
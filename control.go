@@ -0,0 +1,453 @@
+package margo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/iota-uz/margo/parser"
+)
+
+// Built-in components evaluated by the renderer.
+//
+//	\If Cond: {{ page.draft }}
+//	    Draft, do not share
+//	\Else
+//	    Published
+//
+//	\Each Items: {{ data.team }} As: "member"
+//	    \Card
+//	        Title: {{ member.name }}
+//
+//	\Define Name: "CTA"
+//	    \ButtonPrimary
+//	        Text: {{ props.text }}
+//
+//	\Include Path: "partials/newsletter.md"
+//
+//	\Fill Name: "sidebar"
+//	    \TableOfContents
+const (
+	IfComponent      = "If"
+	ElseComponent    = "Else"
+	EachComponent    = "Each"
+	DefineComponent  = "Define"
+	IncludeComponent = "Include"
+	FillComponent    = "Fill"
+)
+
+var definitionsKey = ContextKey{"definitions"}
+var includerKey = ContextKey{"includer"}
+var includesKey = ContextKey{"includes"}
+
+// Includer reads the markdown file at path for an \Include.
+type Includer func(path string) ([]byte, error)
+
+// WithDefinitions makes the fragments declared with \Define available as components,
+// replacing the ones of the same name already in ctx.
+func WithDefinitions(ctx context.Context, defs map[string]*parser.ComponentNode) context.Context {
+	scoped := maps.Clone(getDefinitions(ctx))
+	if scoped == nil {
+		scoped = make(map[string]*parser.ComponentNode, len(defs))
+	}
+	maps.Copy(scoped, defs)
+	return context.WithValue(ctx, definitionsKey, scoped)
+}
+
+func getDefinitions(ctx context.Context) map[string]*parser.ComponentNode {
+	defs, _ := ctx.Value(definitionsKey).(map[string]*parser.ComponentNode)
+	return defs
+}
+
+// WithIncluder sets the function reading the files of \Include.
+func WithIncluder(ctx context.Context, includer Includer) context.Context {
+	return context.WithValue(ctx, includerKey, includer)
+}
+
+// Definitions returns the fragments declared with \Define at the top level of
+// the margo blocks of the page, keyed by name.
+func (p *Page) Definitions() (map[string]*parser.ComponentNode, error) {
+	defs := make(map[string]*parser.ComponentNode)
+	for _, block := range p.Blocks {
+		for _, node := range block.Nodes {
+			c, ok := node.(*parser.ComponentNode)
+			if !ok || c.Name != DefineComponent {
+				continue
+			}
+			name, err := definitionName(c)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", c.Span().Start.Line, err)
+			}
+			if _, ok := defs[name]; ok {
+				return nil, fmt.Errorf("line %d: %s is defined twice", c.Span().Start.Line, name)
+			}
+			defs[name] = c
+		}
+	}
+	return defs, nil
+}
+
+// Fills returns the content of the slots filled with \Fill at the top level of the
+// margo blocks of the page, keyed by slot name, see WithNamedSlot.
+func (p *Page) Fills() (map[string]*parser.Document, error) {
+	fills := make(map[string]*parser.Document)
+	for _, block := range p.Blocks {
+		for _, node := range block.Nodes {
+			c, ok := node.(*parser.ComponentNode)
+			if !ok || c.Name != FillComponent {
+				continue
+			}
+			name, err := slotName(c)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", c.Span().Start.Line, err)
+			}
+			if _, ok := fills[name]; ok {
+				return nil, fmt.Errorf("line %d: slot %q is filled twice", c.Span().Start.Line, name)
+			}
+			fills[name] = &parser.Document{
+				Children: c.Children(),
+				Line:     c.Span().Start.Line,
+				Column:   c.Span().Start.Column,
+			}
+		}
+	}
+	return fills, nil
+}
+
+// Slots returns the names of the named slots of a layout file, in order.
+func (p *Page) Slots() []string {
+	var names []string
+	p.walk(func(c *parser.ComponentNode) {
+		if c.Name != slotComponent || len(c.Properties()) == 0 {
+			return
+		}
+		if name, err := slotName(c); err == nil && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	})
+	return names
+}
+
+// Includes returns the paths of the files included by the page with a literal Path,
+// paths made of expressions being known at render time only.
+func (p *Page) Includes() []string {
+	var paths []string
+	p.walk(func(c *parser.ComponentNode) {
+		if c.Name != IncludeComponent {
+			return
+		}
+		if path, err := includePath(c); err == nil && !strings.Contains(path, "{{") && !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	})
+	return paths
+}
+
+// walk calls fn for every component of the margo blocks of the page, children included.
+func (p *Page) walk(fn func(c *parser.ComponentNode)) {
+	var walk func(nodes []parser.Node)
+	walk = func(nodes []parser.Node) {
+		for _, node := range nodes {
+			if c, ok := node.(*parser.ComponentNode); ok {
+				fn(c)
+				walk(c.Children())
+			}
+		}
+	}
+	for _, block := range p.Blocks {
+		walk(block.Nodes)
+	}
+}
+
+// definitionName returns the name of the fragment declared by a \Define.
+func definitionName(node *parser.ComponentNode) (string, error) {
+	attrs := node.Properties()
+	if len(attrs) != 1 || attrs[0].Name != "Name" {
+		return "", errors.New(`\Define takes a single Name property`)
+	}
+	name, ok := attrs[0].Value.(string)
+	if !ok || !isComponentName(name) {
+		return "", fmt.Errorf(`\Define Name must be a component name, got %v`, attrs[0].Value)
+	}
+	switch name {
+	case IfComponent, ElseComponent, EachComponent, DefineComponent, IncludeComponent, FillComponent, slotComponent:
+		return "", fmt.Errorf(`cannot redefine the built-in \%s`, name)
+	}
+	return name, nil
+}
+
+// isComponentName reports whether name can be written as \name in a margo block.
+func isComponentName(name string) bool {
+	if name == "" || name[0] < 'A' || name[0] > 'Z' {
+		return false
+	}
+	for _, c := range []byte(name) {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// slotName returns the Name property of a \Slot or a \Fill.
+func slotName(node *parser.ComponentNode) (string, error) {
+	attrs := node.Properties()
+	if len(attrs) != 1 || attrs[0].Name != "Name" {
+		return "", fmt.Errorf(`\%s takes a single Name property`, node.Name)
+	}
+	name, ok := attrs[0].Value.(string)
+	if !ok || name == "" {
+		return "", fmt.Errorf(`\%s Name must be a slot name, got %v`, node.Name, attrs[0].Value)
+	}
+	return name, nil
+}
+
+// includePath returns the Path property of an \Include, before interpolation.
+func includePath(node *parser.ComponentNode) (string, error) {
+	attrs := node.Properties()
+	if len(attrs) != 1 || attrs[0].Name != "Path" {
+		return "", errors.New(`\Include takes a single Path property`)
+	}
+	path, ok := attrs[0].Value.(string)
+	if !ok || path == "" {
+		return "", fmt.Errorf(`\Include Path must be a file path, got %v`, attrs[0].Value)
+	}
+	return path, nil
+}
+
+// renderFunc renders a single node of a margo block.
+type renderFunc func(ctx context.Context, w io.Writer, node parser.Node) error
+
+// renderNodes renders sibling nodes with render, evaluating the built-in \If, \Else and \Each.
+// An \Else renders its children when the condition of the \If right before it is false.
+func (nr *NodeRenderer) renderNodes(ctx context.Context, w io.Writer, nodes []parser.Node, render renderFunc) error {
+	// result of the previous \If, nil when the previous node is not an \If
+	var prevIf *bool
+	for _, node := range nodes {
+		c, ok := node.(*parser.ComponentNode)
+		if !ok {
+			prevIf = nil
+			if err := render(ctx, w, node); err != nil {
+				return err
+			}
+			continue
+		}
+		var err error
+		switch c.Name {
+		case IfComponent:
+			var cond bool
+			cond, err = nr.renderIf(ctx, w, c, render)
+			prevIf = &cond
+		case ElseComponent:
+			if prevIf == nil {
+				err = newRenderError(withComponent(ctx, c.Name), errors.New(`\Else must follow an \If`))
+			} else if !*prevIf {
+				err = nr.renderNodes(withComponent(ctx, c.Name), w, c.Children(), render)
+			}
+			prevIf = nil
+		case EachComponent:
+			err = nr.renderEach(ctx, w, c, render)
+			prevIf = nil
+		case DefineComponent, FillComponent:
+			// collected by the loader, see Page.Definitions and Page.Fills
+			prevIf = nil
+		case IncludeComponent:
+			err = nr.renderInclude(ctx, w, c)
+			prevIf = nil
+		default:
+			err = render(ctx, w, node)
+			prevIf = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderIf renders the children of node when its Cond property is truthy, or its Not
+// property is not, and reports the result of the condition.
+// Unknown variables are false, so optional front-matter can be tested.
+func (nr *NodeRenderer) renderIf(ctx context.Context, w io.Writer, node *parser.ComponentNode, render renderFunc) (cond bool, err error) {
+	ctx = withComponent(ctx, node.Name)
+	defer func() {
+		if err != nil {
+			err = newRenderError(ctx, err)
+		}
+	}()
+	attrs := node.Properties()
+	if len(attrs) != 1 || (attrs[0].Name != "Cond" && attrs[0].Name != "Not") {
+		return false, errors.New(`\If takes a single Cond or Not property`)
+	}
+	v, err := resolveCondition(ctx, attrs[0].Value)
+	if err != nil && !errors.Is(err, errUnknownVariable) {
+		return false, err
+	}
+	cond = truthy(v)
+	if attrs[0].Name == "Not" {
+		cond = !cond
+	}
+	if !cond {
+		return false, nil
+	}
+	return true, nr.renderNodes(ctx, w, node.Children(), render)
+}
+
+// renderEach renders the children of node once per entry of its Items property.
+// The entry is available as {{ item }}, or the name set with the As property, and its
+// position as {{ loop.index }}, {{ loop.first }} and {{ loop.last }}.
+func (nr *NodeRenderer) renderEach(ctx context.Context, w io.Writer, node *parser.ComponentNode, render renderFunc) (err error) {
+	ctx = withComponent(ctx, node.Name)
+	defer func() {
+		if err != nil {
+			err = newRenderError(ctx, err)
+		}
+	}()
+	var items any
+	name := "item"
+	for _, attr := range node.Properties() {
+		switch attr.Name {
+		case "Items":
+			if items, err = resolveValue(ctx, attr.Value); err != nil {
+				return err
+			}
+		case "As":
+			as, ok := attr.Value.(string)
+			if !ok || as == "" {
+				return fmt.Errorf(`\Each As must be a name, got %v`, attr.Value)
+			}
+			name = as
+		default:
+			return fmt.Errorf(`unknown prop %s of \Each, expected Items or As`, attr.Name)
+		}
+	}
+	var list parser.List
+	switch v := items.(type) {
+	case nil:
+		// nothing to render
+	case parser.List:
+		list = v
+	default:
+		return fmt.Errorf(`\Each Items must be a list, got %T`, items)
+	}
+	for i, item := range list {
+		itemCtx := withVar(ctx, name, item)
+		itemCtx = withVar(itemCtx, "loop", parser.Object{
+			{Name: "index", Value: int64(i)},
+			{Name: "first", Value: i == 0},
+			{Name: "last", Value: i == len(list)-1},
+		})
+		if err := nr.renderNodes(itemCtx, w, node.Children(), render); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderFragment renders a fragment declared with \Define in place of node,
+// the props of node being available to the fragment as {{ props.* }}.
+func (nr *NodeRenderer) renderFragment(ctx context.Context, w io.Writer, def, node *parser.ComponentNode) error {
+	chain, _ := ctx.Value(componentsKey).([]string)
+	if slices.Contains(chain[:len(chain)-1], node.Name) {
+		return fmt.Errorf("fragment %s renders itself", node.Name)
+	}
+	if len(node.Children()) > 0 {
+		return fmt.Errorf("fragment %s does not take children", node.Name)
+	}
+	props := make(parser.Object, 0, len(node.Properties()))
+	for _, attr := range node.Properties() {
+		v, err := resolveValue(ctx, attr.Value)
+		if err != nil {
+			return err
+		}
+		props = append(props, &parser.Attribute{Name: attr.Name, Value: v, Span: attr.Span})
+	}
+	err := nr.renderNodes(withVar(ctx, "props", props), w, def.Children(), func(ctx context.Context, w io.Writer, node parser.Node) error {
+		return nr.renderComponent(ctx, w, node, nil)
+	})
+	// the fragment may be declared in another file, its errors are reported where it is used
+	var renderErr *RenderError
+	if errors.As(err, &renderErr) && renderErr.Path == "" && node.Span().Start.Line > 0 {
+		renderErr.Line = node.Span().Start.Line
+		renderErr.Column = node.Span().Start.Column
+	}
+	return err
+}
+
+// renderInclude renders the markdown file named by the Path property of node
+// with the layout of the page. Files including each other are reported.
+func (nr *NodeRenderer) renderInclude(ctx context.Context, w io.Writer, node *parser.ComponentNode) (err error) {
+	ctx = withPosition(withComponent(ctx, node.Name), node.Span().Start)
+	defer func() {
+		if err != nil {
+			err = newRenderError(ctx, err)
+		}
+	}()
+	path, err := includePath(node)
+	if err != nil {
+		return err
+	}
+	if path, err = interpolate(ctx, path); err != nil {
+		return err
+	}
+	includer, ok := ctx.Value(includerKey).(Includer)
+	if !ok {
+		return errors.New(`\Include is not supported without an includer, see WithIncluder`)
+	}
+	chain, _ := ctx.Value(includesKey).([]string)
+	if slices.Contains(chain, path) {
+		return fmt.Errorf("include cycle: %s > %s", strings.Join(chain, " > "), path)
+	}
+	source, err := includer(path)
+	if err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, includesKey, append(slices.Clip(chain), path))
+	err = New(nr.layout).ConvertToTempl(source, WithFile(path)).Render(ctx, w)
+	var renderErr *RenderError
+	if errors.As(err, &renderErr) && renderErr.Path == "" {
+		renderErr.Path = path
+	}
+	return err
+}
+
+// resolveCondition resolves the value of a condition. A quoted string holding a single
+// expression keeps the type of its value, "{{ page.draft }}" being false when draft is.
+func resolveCondition(ctx context.Context, v any) (any, error) {
+	if s, ok := v.(string); ok {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "{{") && strings.HasSuffix(s, "}}") {
+			if expr, err := parser.ParseExpr(s); err == nil {
+				v = expr
+			}
+		}
+	}
+	return resolveValue(ctx, v)
+}
+
+// truthy reports whether v counts as true in a condition.
+// Empty values, zero and false are false.
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case parser.List:
+		return len(v) > 0
+	case parser.Object:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
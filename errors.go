@@ -0,0 +1,185 @@
+package margo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/iota-uz/margo/parser"
+)
+
+// RenderError describes a failure while rendering a margo block.
+// Ex.: "docs/intro.md:12:1: HeroV2 > ButtonPrimary: missing required props of ButtonPrimary: Href"
+type RenderError struct {
+	// Path of the markdown file, set by the loader.
+	Path string
+	// Line and Column locate the failing component in the markdown file,
+	// or the margo block when the component has no position.
+	Line   int
+	Column int
+	// Components is the chain of components being rendered, outermost first.
+	Components []string
+	Err        error
+}
+
+func (e *RenderError) Error() string {
+	var b strings.Builder
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(":")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d:", e.Line, e.Column)
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	if len(e.Components) > 0 {
+		b.WriteString(strings.Join(e.Components, " > "))
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// PropError describes a prop whose value does not bind to the props of a component.
+// Ex.: "prop Date of Event, expected time.Time: failed to parse time "soon", ..."
+type PropError struct {
+	// Component is the name of the component, empty for markdown elements.
+	Component string
+	// Prop is the name of the props field.
+	Prop string
+	// Type is the type of the props field.
+	Type reflect.Type
+	Err  error
+}
+
+func (e *PropError) Error() string {
+	if e.Component == "" {
+		return fmt.Sprintf("prop %s, expected %s: %v", e.Prop, e.Type, e.Err)
+	}
+	return fmt.Sprintf("prop %s of %s, expected %s: %v", e.Prop, e.Component, e.Type, e.Err)
+}
+
+func (e *PropError) Unwrap() error {
+	return e.Err
+}
+
+// MissingPropsError lists the required props left unset on a component.
+// Ex.: "missing required props of ButtonPrimary: Href, Text"
+type MissingPropsError struct {
+	// Component is the name of the component, empty for markdown elements.
+	Component string
+	Props     []string
+}
+
+func (e *MissingPropsError) Error() string {
+	if e.Component == "" {
+		return fmt.Sprintf("missing required props: %s", strings.Join(e.Props, ", "))
+	}
+	return fmt.Sprintf("missing required props of %s: %s", e.Component, strings.Join(e.Props, ", "))
+}
+
+// UnknownPropError lists the props that match no field of the props of a component.
+// Ex.: "unknown prop of Hero: Titel (did you mean Title?); valid props: Count int, Title string"
+type UnknownPropError struct {
+	// Component is the name of the component, empty for markdown elements.
+	Component string
+	Props     []string
+	// Suggestions holds the closest valid props of the unknown ones, if any.
+	Suggestions map[string][]string
+	// Valid lists the valid props with their type. Ex.: "Title string"
+	Valid []string
+}
+
+func (e *UnknownPropError) Error() string {
+	var b strings.Builder
+	b.WriteString("unknown prop")
+	if len(e.Props) > 1 {
+		b.WriteString("s")
+	}
+	if e.Component != "" {
+		b.WriteString(" of ")
+		b.WriteString(e.Component)
+	}
+	b.WriteString(": ")
+	for i, prop := range e.Props {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(prop)
+		if hint := didYouMean(e.Suggestions[prop]); hint != "" {
+			fmt.Fprintf(&b, " (%s)", hint)
+		}
+	}
+	if len(e.Valid) > 0 {
+		b.WriteString("; valid props: ")
+		b.WriteString(strings.Join(e.Valid, ", "))
+	} else {
+		b.WriteString("; the component takes no props")
+	}
+	return b.String()
+}
+
+var componentsKey = ContextKey{"components"}
+var positionKey = ContextKey{"position"}
+
+// withComponent appends name to the chain of components being rendered.
+func withComponent(ctx context.Context, name string) context.Context {
+	chain, _ := ctx.Value(componentsKey).([]string)
+	return context.WithValue(ctx, componentsKey, append(slices.Clip(chain), name))
+}
+
+// currentComponent returns the innermost component being rendered.
+func currentComponent(ctx context.Context) string {
+	chain, _ := ctx.Value(componentsKey).([]string)
+	if len(chain) == 0 {
+		return ""
+	}
+	return chain[len(chain)-1]
+}
+
+// withBlock records the position of the margo block being rendered.
+func withBlock(ctx context.Context, doc *parser.Document) context.Context {
+	return withPosition(ctx, parser.Position{Line: doc.Line, Column: doc.Column})
+}
+
+// withPosition records the position of the block or component being rendered,
+// positions left unset by the parser keeping the enclosing one.
+func withPosition(ctx context.Context, pos parser.Position) context.Context {
+	if pos.Line == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, positionKey, pos)
+}
+
+// newRenderError annotates err with the position and component chain found in ctx.
+// Errors that are already a *RenderError are returned as is, so the innermost
+// component is reported. Syntax errors are positioned already and returned as is.
+func newRenderError(ctx context.Context, err error) error {
+	var renderErr *RenderError
+	if errors.As(err, &renderErr) {
+		return err
+	}
+	var diags parser.Diagnostics
+	if errors.As(err, &diags) {
+		return err
+	}
+	chain, _ := ctx.Value(componentsKey).([]string)
+	renderErr = &RenderError{
+		Components: chain,
+		Err:        err,
+	}
+	if pos, ok := ctx.Value(positionKey).(parser.Position); ok {
+		renderErr.Line = pos.Line
+		renderErr.Column = pos.Column
+	}
+	return renderErr
+}
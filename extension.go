@@ -61,6 +61,14 @@ func New(reg registry.Layout) Markdown {
 	return md
 }
 
+// WithFile returns a parse option recording the path of the parsed file,
+// so syntax errors in margo blocks are reported relative to it.
+func WithFile(path string) parser.ParseOption {
+	pc := parser.NewContext()
+	pc.Set(margoparser.FileKey, path)
+	return parser.WithContext(pc)
+}
+
 func (m *markdown) Convert(source []byte, writer io.Writer, opts ...parser.ParseOption) error {
 	reader := text.NewReader(source)
 	doc := m.parser.Parse(reader, opts...)
@@ -106,6 +114,10 @@ func (e *extender) Extend(m goldmark.Markdown) {
 	m.Parser().AddOptions(
 		parser.WithBlockParsers(
 			util.Prioritized(margoparser.BlockParser(), 10),
+			util.Prioritized(margoparser.DirectiveBlockParser(), 10),
+		),
+		parser.WithInlineParsers(
+			util.Prioritized(margoparser.InlineParser(), 100),
 		),
 	)
 	m.Renderer().AddOptions(
@@ -0,0 +1,221 @@
+// Package format rewrites the margo blocks of markdown files in canonical form,
+// see parser.Print.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/iota-uz/margo/lexer"
+	"github.com/iota-uz/margo/parser"
+)
+
+const margoFence = "```margo"
+
+// SkippedBlock is a margo block left as is by the formatter.
+type SkippedBlock struct {
+	File string
+	// Line is the line of the opening fence.
+	Line   int
+	Reason string
+	// Comments is set when the block was left as is because it holds comments,
+	// which the parser drops. Unlike other blocks, it needs no fixing.
+	Comments bool
+}
+
+func (b SkippedBlock) String() string {
+	if b.File == "" {
+		return fmt.Sprintf("%d: margo block left as is, %s", b.Line, b.Reason)
+	}
+	return fmt.Sprintf("%s:%d: margo block left as is, %s", b.File, b.Line, b.Reason)
+}
+
+// Files formats the markdown files at paths, directories being walked recursively.
+// In check mode the files are left untouched.
+// It returns the files that were not formatted and the margo blocks left as is,
+// errors being reported per file.
+func Files(paths []string, check bool) ([]string, []SkippedBlock, error) {
+	var changed []string
+	var skipped []SkippedBlock
+	var errs []error
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || filepath.Ext(path) != ".md" {
+				return nil
+			}
+			ok, fileSkipped, err := File(path, check)
+			if err != nil {
+				errs = append(errs, err)
+				return nil
+			}
+			skipped = append(skipped, fileSkipped...)
+			if !ok {
+				changed = append(changed, path)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return changed, skipped, errors.Join(errs...)
+}
+
+// File formats the markdown file at path in place, unless in check mode.
+// It reports whether the file was already formatted, and the margo blocks left as is.
+func File(path string, check bool) (bool, []SkippedBlock, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return false, nil, err
+	}
+	formatted, skipped, err := SourceBlocks(src, parser.WithFile(path))
+	if err != nil {
+		return false, nil, err
+	}
+	for i := range skipped {
+		skipped[i].File = path
+	}
+	if bytes.Equal(src, formatted) {
+		return true, skipped, nil
+	}
+	if check {
+		return false, skipped, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, nil, err
+	}
+	if err := os.WriteFile(path, formatted, info.Mode().Perm()); err != nil {
+		return false, nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return false, skipped, nil
+}
+
+// Source formats every margo block of the markdown src, see SourceBlocks.
+func Source(src []byte, opts ...parser.Option) ([]byte, error) {
+	formatted, _, err := SourceBlocks(src, opts...)
+	return formatted, err
+}
+
+// SourceBlocks formats every margo block of the markdown src, returning the blocks left as is:
+// blocks holding comments, which the parser drops, and blocks the printer cannot express.
+// Syntax errors are reported as parser.Diagnostics, positioned in src.
+func SourceBlocks(src []byte, opts ...parser.Option) ([]byte, []SkippedBlock, error) {
+	lines := strings.SplitAfter(string(src), "\n")
+	var out strings.Builder
+	var skipped []SkippedBlock
+	var diags parser.Diagnostics
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		out.WriteString(line)
+		fence := openingFence(line)
+		if fence == "" {
+			continue
+		}
+		margo := strings.HasPrefix(line, margoFence)
+		end := closingFence(lines, i+1, fence, margo)
+		if !margo {
+			// a code block, possibly showing margo blocks as examples
+			for _, l := range lines[i+1 : end] {
+				out.WriteString(l)
+			}
+			i = closeBlock(&out, lines, end)
+			continue
+		}
+		content := strings.Join(lines[i+1:end], "")
+		formatted, reason, comments, err := block(content, append(opts, parser.WithLineOffset(i+1))...)
+		if err != nil {
+			var d parser.Diagnostics
+			if !errors.As(err, &d) {
+				return nil, nil, err
+			}
+			diags = append(diags, d...)
+		}
+		if reason != "" {
+			skipped = append(skipped, SkippedBlock{Line: i + 1, Reason: reason, Comments: comments})
+		}
+		out.WriteString(formatted)
+		i = closeBlock(&out, lines, end)
+	}
+	if len(diags) > 0 {
+		return nil, nil, diags
+	}
+	return []byte(out.String()), skipped, nil
+}
+
+// block formats the content of a margo block, returning it as is along with
+// the reason when it cannot be formatted safely, and whether it holds comments.
+func block(content string, opts ...parser.Option) (string, string, bool, error) {
+	if hasComment(content) {
+		return content, "it holds comments", true, nil
+	}
+	nodes, err := parser.NewMargoParser(content, opts...).Parse()
+	if err != nil {
+		return content, "", false, err
+	}
+	formatted, err := parser.Print(nodes)
+	if err != nil {
+		return content, err.Error(), false, nil
+	}
+	return formatted, "", false, nil
+}
+
+// hasComment reports whether content holds comments, as read by the lexer:
+// `//` within a string is not a comment.
+func hasComment(content string) bool {
+	l := lexer.New(content)
+	for l.Next().Type != lexer.EOF {
+	}
+	return l.HasComments()
+}
+
+// closeBlock writes the line closing a code block, end, if any, and returns its index
+// so that it is not taken for the opening fence of another block.
+func closeBlock(out *strings.Builder, lines []string, end int) int {
+	if end < len(lines) {
+		out.WriteString(lines[end])
+	}
+	return end
+}
+
+// openingFence returns the fence a line opens a code block with, or "".
+// Ex.: "```margo" -> "```", "````markdown" -> "````"
+func openingFence(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) == 0 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return ""
+	}
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+	if n < 3 {
+		return ""
+	}
+	return trimmed[:n]
+}
+
+// closingFence returns the index of the line closing a code block opened with fence,
+// or len(lines) when the block runs to the end of the file.
+// Like the block parser, margo blocks are closed by any line starting with three backticks.
+func closingFence(lines []string, start int, fence string, margo bool) int {
+	for i := start; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t\r\n")
+		if margo {
+			if strings.HasPrefix(line, "```") {
+				return i
+			}
+			continue
+		}
+		trimmed := strings.TrimLeft(line, " ")
+		if len(line)-len(trimmed) <= 3 && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			return i
+		}
+	}
+	return len(lines)
+}
@@ -0,0 +1,73 @@
+package format
+
+import (
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/iota-uz/margo/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	input := "# Title\n\n" +
+		"```margo\n\\Hero Title: \"Hi\"\n\t\t!Wide\n\n\n\\Footer\n```\n\n" +
+		"````markdown\n```margo\n\\Example   Title: \"kept\"\n```\n````\n\n" +
+		"```margo\n// draft\n\\Card   Title: \"kept\"\n```\n\n" +
+		"```margo\n\\Code\n  Source: \"\"\"\n    // not a comment\n    \"\"\"\n```\n"
+	expected := "# Title\n\n" +
+		"```margo\n\\Hero\n    Title: \"Hi\"\n    !Wide\n\n\\Footer\n```\n\n" +
+		"````markdown\n```margo\n\\Example   Title: \"kept\"\n```\n````\n\n" +
+		"```margo\n// draft\n\\Card   Title: \"kept\"\n```\n\n" +
+		"```margo\n\\Code\n    Source: \"\"\"// not a comment\"\"\"\n```\n"
+
+	out, err := Source([]byte(input))
+	if err != nil {
+		t.Fatalf("Source() failed: %v", err)
+	}
+	if diff := cmp.Diff(expected, string(out)); diff != "" {
+		t.Errorf("Source() mismatch (-want +got):\n%s", diff)
+	}
+
+	again, err := Source(out)
+	if err != nil {
+		t.Fatalf("Source() failed: %v", err)
+	}
+	if string(again) != string(out) {
+		t.Errorf("Source() is not idempotent:\n%s", again)
+	}
+}
+
+func TestSourceDiagnostics(t *testing.T) {
+	input := "# Title\n\n```margo\n\\Hero\n    Title \"Hi\"\n    Count: x\n```\n"
+	_, err := Source([]byte(input), parser.WithFile("index.md"))
+	var diags parser.Diagnostics
+	if !errors.As(err, &diags) || len(diags) != 1 {
+		t.Fatalf("expected a diagnostic, got %v", err)
+	}
+	if diags[0].File != "index.md" || diags[0].Line != 6 {
+		t.Errorf("expected the diagnostic at index.md:6, got %v", diags[0])
+	}
+}
+
+func TestFileSkippedBlocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.md")
+	input := "# Title\n\n```margo\n\\Hero\n    Title: \"Hi\"\n```\n\n```margo\n// draft\n\\Card   Title: \"kept\"\n```\n"
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ok, skipped, err := File(path, true)
+	if err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if !ok {
+		t.Error("expected the file to be formatted")
+	}
+	expected := []SkippedBlock{{File: path, Line: 8, Reason: "it holds comments", Comments: true}}
+	if diff := cmp.Diff(expected, skipped); diff != "" {
+		t.Errorf("skipped blocks mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := skipped[0].String(), path+":8: margo block left as is, it holds comments"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
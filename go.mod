@@ -10,10 +10,10 @@ require (
 	github.com/yuin/goldmark v1.7.8
 	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20230729083705-37449abec8cc
 	github.com/yuin/goldmark-meta v1.1.0
+	gopkg.in/yaml.v2 v2.3.0
 )
 
 require (
 	github.com/dlclark/regexp2 v1.11.4 // indirect
 	golang.org/x/sys v0.28.0 // indirect
-	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
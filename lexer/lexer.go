@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -10,7 +11,7 @@ type TokenType int
 const (
 	EOF       TokenType = iota // End of file
 	LineBreak                  // \n
-	Indent                     // "    " (4 spaces)
+	Indent                     // a tab or a level of spaces, see WithIndentWidth
 	Component                  // \HeroV2, \Button, \Card
 	Property                   // Title, Href, Description
 	Text                       // "Hello World", "/demo"
@@ -18,6 +19,13 @@ const (
 	Bool                       // !Visible, !Hidden
 	Colon                      // :
 	Quote                      // "
+	Illegal                    // invalid input, Value holds the reason
+	Boolean                    // true, false
+	Null                       // null
+	Dash                       // - list item
+	Expr                       // {{ page.title }}
+	RawString                  // """raw text""", Value holds the text
+	Folded                     // > followed by indented lines, Value holds the folded text
 )
 
 func (t TokenType) String() string {
@@ -32,22 +40,46 @@ func (t TokenType) String() string {
 		"Bool",
 		"Colon",
 		"Quote",
+		"Illegal",
+		"Boolean",
+		"Null",
+		"Dash",
+		"Expr",
+		"RawString",
+		"Folded",
 	}[t]
 }
 
 type Token struct {
-	Type   TokenType
-	Value  string
+	Type  TokenType
+	Value string
+	// Offset is the byte offset of the token in the input.
+	Offset int
 	Line   int
 	Column int
 }
 
-func New(input string) *Lexer {
-	return &Lexer{
+// Option configures the lexer.
+type Option func(*Lexer)
+
+// WithIndentWidth sets the number of spaces of an indentation level,
+// instead of detecting it from the first line indented with spaces.
+func WithIndentWidth(n int) Option {
+	return func(l *Lexer) {
+		l.indentWidth = n
+	}
+}
+
+func New(input string, opts ...Option) *Lexer {
+	l := &Lexer{
 		input:  input,
 		line:   1,
 		column: 1,
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
 }
 
 type Lexer struct {
@@ -56,22 +88,44 @@ type Lexer struct {
 	line   int
 	column int
 	prev   Token
+	// indentWidth is the number of spaces of an indentation level,
+	// 0 until detected.
+	indentWidth int
+	// comments is set once a comment was skipped.
+	comments bool
 }
 
 func (l *Lexer) next() Token {
 	for {
 		if l.isEOF() {
-			return Token{Type: EOF}
+			return Token{Type: EOF, Offset: l.pos, Line: l.line, Column: l.column}
+		}
+
+		if l.skipBlankLine() {
+			continue
 		}
 
 		if l.isIndent() {
 			return l.lexIndent()
 		}
 
+		if l.prev.Type == Colon || l.prev.Type == Dash {
+			if strings.HasPrefix(l.input[l.pos:], `"""`) {
+				return l.lexRawString()
+			}
+			if l.isFolded() {
+				return l.lexFolded()
+			}
+		}
+
 		if l.prev.Type == Quote && l.lookAhead('"', '\n') != -1 {
 			return l.lexString()
 		}
 
+		if l.skipComment() {
+			continue
+		}
+
 		start := l.pos
 		line := l.line
 		column := l.column
@@ -87,6 +141,7 @@ func (l *Lexer) next() Token {
 			return Token{
 				Type:   Quote,
 				Value:  "\"",
+				Offset: start,
 				Line:   line,
 				Column: column,
 			}
@@ -95,6 +150,7 @@ func (l *Lexer) next() Token {
 			return Token{
 				Type:   Colon,
 				Value:  ":",
+				Offset: start,
 				Line:   line,
 				Column: column,
 			}
@@ -102,6 +158,23 @@ func (l *Lexer) next() Token {
 			return l.lexNewline()
 		case '!':
 			return l.lexBoolProperty()
+		case '-':
+			if l.isDash() {
+				l.advance()
+				return Token{
+					Type:   Dash,
+					Value:  "-",
+					Offset: start,
+					Line:   line,
+					Column: column,
+				}
+			}
+		}
+
+		if l.prev.Type == Colon || l.prev.Type == Dash {
+			if token, ok := l.lexLiteral(); ok {
+				return token
+			}
 		}
 
 		if l.isProperty() {
@@ -114,6 +187,7 @@ func (l *Lexer) next() Token {
 		return Token{
 			Type:   Text,
 			Value:  l.input[start:l.pos],
+			Offset: start,
 			Line:   line,
 			Column: column,
 		}
@@ -143,6 +217,17 @@ func (l *Lexer) Next() Token {
 	return l.prev
 }
 
+// HasComments reports whether a comment was skipped so far.
+// The parser drops comments, tools rewriting the input can check it to keep them.
+func (l *Lexer) HasComments() bool {
+	return l.comments
+}
+
+// Pos returns the offset, line and column right after the last token returned by Next.
+func (l *Lexer) Pos() (offset, line, column int) {
+	return l.pos, l.line, l.column
+}
+
 func (l *Lexer) Peek() Token {
 	start := l.pos
 	line := l.line
@@ -159,6 +244,7 @@ func (l *Lexer) isEOF() bool {
 }
 
 func (l *Lexer) lexComponent() Token {
+	offset := l.pos
 	column := l.column
 	l.advance() // skip backslash
 	start := l.pos
@@ -170,12 +256,15 @@ func (l *Lexer) lexComponent() Token {
 	return Token{
 		Type:   Component,
 		Value:  l.input[start:l.pos],
+		Offset: offset,
 		Line:   l.line,
 		Column: column,
 	}
 }
 
 func (l *Lexer) lexBoolProperty() Token {
+	offset := l.pos
+	column := l.column
 	l.advance() // skip !
 	start := l.pos
 
@@ -186,9 +275,45 @@ func (l *Lexer) lexBoolProperty() Token {
 	return Token{
 		Type:   Bool,
 		Value:  l.input[start:l.pos],
+		Offset: offset,
 		Line:   l.line,
-		Column: l.column,
+		Column: column,
+	}
+}
+
+// skipComment skips a comment starting the line: a line comment running to the
+// end of the line, or a block comment that may span several lines.
+// A block comment that is never closed is left as text.
+//
+//	// \Section
+//	/* \Section
+//	    Title: "Draft" */
+func (l *Lexer) skipComment() bool {
+	if l.pos != 0 && l.prev.Type != Indent && l.prev.Type != LineBreak {
+		return false
+	}
+	rest := l.input[l.pos:]
+	switch {
+	case strings.HasPrefix(rest, "//"):
+		for l.pos < len(l.input) && l.current() != '\n' {
+			l.advance()
+		}
+	case strings.HasPrefix(rest, "/*"):
+		end := strings.Index(rest[2:], "*/")
+		if end == -1 {
+			return false
+		}
+		for range end + 4 {
+			l.advance()
+		}
+		for l.current() == ' ' || l.current() == '\t' {
+			l.advance()
+		}
+	default:
+		return false
 	}
+	l.comments = true
+	return true
 }
 
 func (l *Lexer) isIndent() bool {
@@ -202,18 +327,53 @@ func (l *Lexer) isIndent() bool {
 }
 
 func (l *Lexer) isProperty() bool {
-	end := l.lookAhead(':', '\n')
-	if end == -1 {
+	return isPropertyAt(l.input, l.pos)
+}
+
+func isPropertyAt(input string, pos int) bool {
+	end := strings.IndexAny(input[pos:], ":\n")
+	if end == -1 || input[pos+end] != ':' {
 		return false
 	}
-	for i := l.pos; i < end; i++ {
-		if !isAlphaNumeric(l.input[i]) {
+	for i := pos; i < pos+end; i++ {
+		// dashes spell kebab-case props, Ex.: button-text
+		if !isAlphaNumeric(input[i]) && (input[i] != '-' || i == pos) {
 			return false
 		}
 	}
 	return true
 }
 
+// isDash reports whether the line starts a list item: a dash followed by a value,
+// a property or nothing. Other lines starting with a dash are text.
+// Ex.: `- "a"`, `- 3`, `- \Link`, `- Name: "Free"`
+func (l *Lexer) isDash() bool {
+	if l.prev.Type != Indent && l.prev.Type != LineBreak {
+		return false
+	}
+	i := l.pos + 1
+	if i < len(l.input) && l.input[i] != ' ' && l.input[i] != '\n' {
+		return false
+	}
+	for i < len(l.input) && l.input[i] == ' ' {
+		i++
+	}
+	if i >= len(l.input) {
+		return true
+	}
+	switch l.input[i] {
+	case '\n', '"', '\\':
+		return true
+	}
+	end := strings.IndexByte(l.input[i:], '\n')
+	if end == -1 {
+		end = len(l.input) - i
+	}
+	value := strings.TrimRight(l.input[i:i+end], " \t\r")
+	return value == "true" || value == "false" || value == "null" || value == ">" ||
+		isNumber(value) || exprLen(value) > 0 || isPropertyAt(l.input, i)
+}
+
 func (l *Lexer) lexProperty() Token {
 	start := l.pos
 	line := l.line
@@ -226,17 +386,59 @@ func (l *Lexer) lexProperty() Token {
 	return Token{
 		Type:   Property,
 		Value:  l.input[start:l.pos],
+		Offset: start,
 		Line:   line,
 		Column: column,
 	}
 }
 
+// lexLiteral lexes a number, boolean, null or expression property value.
+// Other than expressions, the literal must be the only thing left on the line,
+// otherwise it is lexed as text.
+func (l *Lexer) lexLiteral() (Token, bool) {
+	end := l.pos
+	for end < len(l.input) && l.input[end] != '\n' {
+		end++
+	}
+	value := strings.TrimRight(l.input[l.pos:end], " \t\r")
+	var typ TokenType
+	switch {
+	case value == "true" || value == "false":
+		typ = Boolean
+	case value == "null":
+		typ = Null
+	case isNumber(value):
+		typ = Number
+	case exprLen(value) > 0:
+		typ = Expr
+		value = value[:exprLen(value)]
+	default:
+		return Token{}, false
+	}
+	token := Token{
+		Type:   typ,
+		Value:  value,
+		Offset: l.pos,
+		Line:   l.line,
+		Column: l.column,
+	}
+	for range value {
+		l.advance()
+	}
+	return token, true
+}
+
+// lexIndent lexes one level of indentation: a tab, or as many spaces as the indent width.
+// Unless set with WithIndentWidth, the width is detected from the first line indented
+// with spaces, which is 2 or 4 spaces. Ex.: 4 spaces -> 4, 6 spaces -> 2
 func (l *Lexer) lexIndent() Token {
+	offset := l.pos
 	line := l.line
 	column := l.column
 	token := Token{
 		Type:   Indent,
 		Value:  "\t",
+		Offset: offset,
 		Line:   line,
 		Column: column,
 	}
@@ -245,47 +447,279 @@ func (l *Lexer) lexIndent() Token {
 		return token
 	}
 	size := 0
-	for l.current() == ' ' {
+	for l.pos+size < len(l.input) && l.input[l.pos+size] == ' ' {
 		size++
-		l.advance()
-		if size == 4 {
-			return token
+	}
+	if l.indentWidth == 0 {
+		switch {
+		case size%4 == 0:
+			l.indentWidth = 4
+		case size%2 == 0:
+			l.indentWidth = 2
+		}
+	}
+	if l.indentWidth == 0 || size < l.indentWidth {
+		for range size {
+			l.advance()
+		}
+		expected := fmt.Sprintf("a multiple of %d spaces", l.indentWidth)
+		if l.indentWidth == 0 {
+			expected = "a tab, 2 or 4 spaces"
 		}
+		return Token{
+			Type:   Illegal,
+			Value:  fmt.Sprintf("invalid indent of %d spaces, expected %s", column-1+size, expected),
+			Offset: offset,
+			Line:   line,
+			Column: column,
+		}
+	}
+	for range l.indentWidth {
+		l.advance()
 	}
-	panic(fmt.Sprintf("invalid indent at line %d, column %d", line, column))
+	return token
+}
+
+// skipBlankLine skips the spaces of a line holding nothing else,
+// which are not indentation.
+func (l *Lexer) skipBlankLine() bool {
+	if l.pos != 0 && l.prev.Type != Indent && l.prev.Type != LineBreak {
+		return false
+	}
+	end := l.pos
+	for end < len(l.input) && (l.input[end] == ' ' || l.input[end] == '\t') {
+		end++
+	}
+	if end == l.pos || (end < len(l.input) && l.input[end] != '\n') {
+		return false
+	}
+	for l.pos < end {
+		l.advance()
+	}
+	return true
 }
 
 func (l *Lexer) lexNewline() Token {
+	offset := l.pos
 	line := l.line
 	column := l.column
 	l.advance()
 	return Token{
 		Type:   LineBreak,
 		Value:  "\n",
+		Offset: offset,
 		Line:   line,
 		Column: column - 1,
 	}
 }
 
+// lexString lexes the content of a quoted string, resolving its escapes:
+// \", \\, \n, \r, \t and \uXXXX. An invalid escape is lexed as Illegal.
 func (l *Lexer) lexString() Token {
 	start := l.pos
 	line := l.line
 	column := l.column
 
-	var prev byte
-	for l.pos < len(l.input) && l.current() != '"' && prev != '\\' {
-		prev = l.current()
+	var b strings.Builder
+	var invalid *Token
+	for l.pos < len(l.input) && l.current() != '"' && l.current() != '\n' {
+		if l.current() != '\\' {
+			b.WriteByte(l.current())
+			l.advance()
+			continue
+		}
+		escape := Token{Type: Illegal, Offset: l.pos, Line: l.line, Column: l.column}
 		l.advance()
+		switch c := l.current(); c {
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			hex := l.input[l.pos+1 : min(l.pos+5, len(l.input))]
+			r, err := strconv.ParseUint(hex, 16, 32)
+			if len(hex) != 4 || err != nil {
+				escape.Value = fmt.Sprintf("invalid escape \\u%s, expected 4 hexadecimal digits", hex)
+				break
+			}
+			b.WriteRune(rune(r))
+			for range 4 {
+				l.advance()
+			}
+		default:
+			escape.Value = fmt.Sprintf("invalid escape \\%c", c)
+		}
+		if escape.Value != "" && invalid == nil {
+			invalid = &escape
+		}
+		if l.current() != '\n' {
+			l.advance()
+		}
+	}
+	if invalid != nil {
+		return *invalid
 	}
 
 	return Token{
 		Type:   Text,
-		Value:  strings.Replace(l.input[start:l.pos], "\\\"", "\"", -1),
+		Value:  b.String(),
+		Offset: start,
 		Line:   line,
 		Column: column,
 	}
 }
 
+// lexRawString lexes a triple-quoted string, kept as is without escapes.
+// When the opening quotes end the line, the string is made of the following lines
+// up to the closing quotes, whose indentation is removed from every line.
+//
+//	Code: """
+//	    fmt.Println("Hello")
+//	    """
+func (l *Lexer) lexRawString() Token {
+	token := Token{Type: RawString, Offset: l.pos, Line: l.line, Column: l.column}
+	illegal := func(format string, args ...any) Token {
+		token.Type = Illegal
+		token.Value = fmt.Sprintf(format, args...)
+		return token
+	}
+	for range 3 {
+		l.advance()
+	}
+	rest := l.input[l.pos:]
+	eol := strings.IndexByte(rest, '\n')
+	if eol == -1 {
+		eol = len(rest)
+	}
+	if end := strings.Index(rest[:eol], `"""`); end != -1 {
+		token.Value = rest[:end]
+		for range end + 3 {
+			l.advance()
+		}
+		return token
+	}
+	if strings.TrimSpace(rest[:eol]) != "" || eol == len(rest) {
+		for range eol {
+			l.advance()
+		}
+		return illegal(`unterminated raw string, expected """ on the line or a new line after the opening """`)
+	}
+
+	lines := strings.Split(rest[eol+1:], "\n")
+	closing := -1
+	var indent string
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, `"""`) {
+			closing = i
+			indent = line[:len(line)-len(trimmed)]
+			break
+		}
+	}
+	if closing == -1 {
+		for l.pos < len(l.input) {
+			l.advance()
+		}
+		return illegal(`unterminated raw string, expected closing """`)
+	}
+	value := make([]string, 0, closing)
+	for i, line := range lines[:closing] {
+		switch {
+		case strings.TrimSpace(line) == "":
+			value = append(value, "")
+		case strings.HasPrefix(line, indent):
+			value = append(value, line[len(indent):])
+		default:
+			token = illegal(`line %d of the raw string is indented less than its closing """`, l.line+1+i)
+		}
+	}
+	size := eol + 1 + len(indent) + 3
+	for _, line := range lines[:closing] {
+		size += len(line) + 1
+	}
+	for range size {
+		l.advance()
+	}
+	if token.Type == Illegal {
+		return token
+	}
+	token.Value = strings.Join(value, "\n")
+	return token
+}
+
+// isFolded reports whether the line ends with the > of a folded value.
+func (l *Lexer) isFolded() bool {
+	if l.current() != '>' {
+		return false
+	}
+	end := strings.IndexByte(l.input[l.pos:], '\n')
+	if end == -1 {
+		end = len(l.input) - l.pos
+	}
+	return strings.TrimSpace(l.input[l.pos+1:l.pos+end]) == ""
+}
+
+// lexFolded lexes a folded value: the lines following the > that are indented more
+// than the line of the >. Lines are joined with spaces, a blank line starting
+// a new line.
+//
+//	Description: >
+//	    A long sentence
+//	    written on two lines.
+func (l *Lexer) lexFolded() Token {
+	token := Token{Type: Folded, Offset: l.pos, Line: l.line, Column: l.column}
+	indent := indentLen(l.input[strings.LastIndexByte(l.input[:l.pos], '\n')+1:])
+	for l.pos < len(l.input) && l.current() != '\n' {
+		l.advance()
+	}
+
+	var paragraphs, words []string
+	blank := 0
+	// pos is at the new line ending the last line read
+	for pos := l.pos; pos < len(l.input); {
+		end := strings.IndexByte(l.input[pos+1:], '\n')
+		if end == -1 {
+			end = len(l.input)
+		} else {
+			end += pos + 1
+		}
+		line := l.input[pos+1 : end]
+		pos = end
+		if strings.TrimSpace(line) == "" {
+			blank++
+			continue
+		}
+		if indentLen(line) <= indent {
+			break
+		}
+		if blank > 0 && len(words) > 0 {
+			paragraphs = append(paragraphs, strings.Join(words, " "))
+			paragraphs = append(paragraphs, make([]string, blank-1)...)
+			words = nil
+		}
+		blank = 0
+		words = append(words, strings.TrimSpace(line))
+		// the value ends with its last line of text
+		for l.pos < end {
+			l.advance()
+		}
+	}
+	if len(words) > 0 {
+		paragraphs = append(paragraphs, strings.Join(words, " "))
+	}
+	token.Value = strings.Join(paragraphs, "\n")
+	return token
+}
+
+// indentLen returns the number of spaces and tabs line starts with.
+func indentLen(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
 // Helper methods
 func (l *Lexer) current() byte {
 	if l.pos >= len(l.input) {
@@ -307,6 +741,59 @@ func (l *Lexer) advance() {
 	l.pos++
 }
 
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// isNumber reports whether s is a decimal number: an optional minus sign,
+// digits, an optional fraction and an optional exponent.
+// Ex.: 3, -12, 0.75, 1e6, 2.5E-3
+func isNumber(s string) bool {
+	i := 0
+	digits := func() int {
+		start := i
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		return i - start
+	}
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	if digits() == 0 {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+// exprLen returns the length of the expression s starts with, or 0.
+// Ex.: "{{ page.title }} As: x" -> 16
+func exprLen(s string) int {
+	if !strings.HasPrefix(s, "{{") {
+		return 0
+	}
+	end := strings.Index(s, "}}")
+	if end == -1 {
+		return 0
+	}
+	return end + 2
+}
+
 func isAlphaNumeric(ch byte) bool {
 	return (ch >= 'a' && ch <= 'z') ||
 		(ch >= 'A' && ch <= 'Z') ||
@@ -25,6 +25,7 @@ func TestLexer(t *testing.T) {
 \Link
 	Title: "Hello, Marshal"
 	Href: "https://example.com"
+	Size: 12
 `,
 			expected: []Token{
 				{Type: Component, Value: "Link"},
@@ -42,11 +43,11 @@ func TestLexer(t *testing.T) {
 				{Type: Quote, Value: "\""},
 				{Type: Text, Value: "https://example.com"},
 				{Type: Quote, Value: "\""},
-				//{Type: LineBreak, Value: "\n"},
-				//{Type: Indent, Value: "\t"},
-				//{Type: Property, Value: "Size"},
-				//{Type: Colon, Value: ":"},
-				//{Type: Number, Value: "12"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Size"},
+				{Type: Colon, Value: ":"},
+				{Type: Number, Value: "12"},
 				{Type: EOF},
 			},
 		},
@@ -158,6 +159,309 @@ func TestLexer(t *testing.T) {
 				{Type: EOF},
 			},
 		},
+		{
+			name: "two space indent",
+			input: `
+\HeroV2
+  !Visible
+  \Button
+      Title: "Go"`,
+			expected: []Token{
+				{Type: Component, Value: "HeroV2"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Bool, Value: "Visible"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Component, Value: "Button"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Indent, Value: "\t"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Title"},
+				{Type: Colon, Value: ":"},
+				{Type: Quote, Value: "\""},
+				{Type: Text, Value: "Go"},
+				{Type: Quote, Value: "\""},
+				{Type: EOF},
+			},
+		},
+		{
+			name: "inconsistent indent",
+			input: `
+\HeroV2
+  !Visible
+   !Wide
+ 
+	!Rounded`,
+			expected: []Token{
+				{Type: Component, Value: "HeroV2"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Bool, Value: "Visible"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Illegal, Value: "invalid indent of 3 spaces, expected a multiple of 2 spaces"},
+				{Type: Bool, Value: "Wide"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Bool, Value: "Rounded"},
+				{Type: EOF},
+			},
+		},
+		{
+			name: "odd indent",
+			input: `
+\HeroV2
+   !Visible`,
+			expected: []Token{
+				{Type: Component, Value: "HeroV2"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Illegal, Value: "invalid indent of 3 spaces, expected a tab, 2 or 4 spaces"},
+				{Type: Bool, Value: "Visible"},
+				{Type: EOF},
+			},
+		},
+		{
+			name: "literals",
+			input: `
+\Gallery
+	Count: -3
+	Ratio: 0.75
+	Visible: false
+	Image: null
+	Title: {{ page.title }}
+	Version: 1.2.3`,
+			expected: []Token{
+				{Type: Component, Value: "Gallery"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Count"},
+				{Type: Colon, Value: ":"},
+				{Type: Number, Value: "-3"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Ratio"},
+				{Type: Colon, Value: ":"},
+				{Type: Number, Value: "0.75"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Visible"},
+				{Type: Colon, Value: ":"},
+				{Type: Boolean, Value: "false"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Image"},
+				{Type: Colon, Value: ":"},
+				{Type: Null, Value: "null"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Title"},
+				{Type: Colon, Value: ":"},
+				{Type: Expr, Value: "{{ page.title }}"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Version"},
+				{Type: Colon, Value: ":"},
+				{Type: Text, Value: "1.2.3"},
+				{Type: EOF},
+			},
+		},
+		{
+			name: "list items",
+			input: `
+\Card
+	Items:
+		- "a"
+		- Name: "b"
+		- plain markdown`,
+			expected: []Token{
+				{Type: Component, Value: "Card"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Items"},
+				{Type: Colon, Value: ":"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Indent, Value: "\t"},
+				{Type: Dash, Value: "-"},
+				{Type: Quote, Value: "\""},
+				{Type: Text, Value: "a"},
+				{Type: Quote, Value: "\""},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Indent, Value: "\t"},
+				{Type: Dash, Value: "-"},
+				{Type: Property, Value: "Name"},
+				{Type: Colon, Value: ":"},
+				{Type: Quote, Value: "\""},
+				{Type: Text, Value: "b"},
+				{Type: Quote, Value: "\""},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Indent, Value: "\t"},
+				{Type: Text, Value: "- plain markdown"},
+				{Type: EOF},
+			},
+		},
+		{
+			name: "comments",
+			input: `
+// hero is disabled while drafting
+\Card
+	// Title: "Draft"
+	/* \Section
+		Title: "Old" */
+	Href: "https://example.com"
+	/* unterminated`,
+			expected: []Token{
+				{Type: LineBreak, Value: "\n"},
+				{Type: Component, Value: "Card"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Href"},
+				{Type: Colon, Value: ":"},
+				{Type: Quote, Value: "\""},
+				{Type: Text, Value: "https://example.com"},
+				{Type: Quote, Value: "\""},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Text, Value: "/* unterminated"},
+				{Type: EOF},
+			},
+		},
+		{
+			name: "escapes",
+			input: `
+\Card
+	Title: "Say \"hi\"\tto \\ caf\u00e9\n"
+	Bad: "\q"`,
+			expected: []Token{
+				{Type: Component, Value: "Card"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Title"},
+				{Type: Colon, Value: ":"},
+				{Type: Quote, Value: "\""},
+				{Type: Text, Value: "Say \"hi\"\tto \\ café\n"},
+				{Type: Quote, Value: "\""},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Bad"},
+				{Type: Colon, Value: ":"},
+				{Type: Quote, Value: "\""},
+				{Type: Illegal, Value: "invalid escape \\q"},
+				{Type: Quote, Value: "\""},
+				{Type: EOF},
+			},
+		},
+		{
+			name: "kebab case property",
+			input: `
+\Button
+	button-text: "Sign up"
+	-text: "x"`,
+			expected: []Token{
+				{Type: Component, Value: "Button"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "button-text"},
+				{Type: Colon, Value: ":"},
+				{Type: Quote, Value: "\""},
+				{Type: Text, Value: "Sign up"},
+				{Type: Quote, Value: "\""},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Text, Value: "-text: \"x\""},
+				{Type: EOF},
+			},
+		},
+		{
+			name: "raw strings",
+			input: `
+\Code
+	Inline: """a "quoted" \n word"""
+	Source: """
+		func main() {
+			fmt.Println("hi")
+
+		}
+		"""
+	!Wide`,
+			expected: []Token{
+				{Type: Component, Value: "Code"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Inline"},
+				{Type: Colon, Value: ":"},
+				{Type: RawString, Value: `a "quoted" \n word`},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Source"},
+				{Type: Colon, Value: ":"},
+				{Type: RawString, Value: "func main() {\n\tfmt.Println(\"hi\")\n\n}"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Bool, Value: "Wide"},
+				{Type: EOF},
+			},
+		},
+		{
+			name: "unterminated raw string",
+			input: `
+\Code
+	Source: """
+		fmt.Println("hi")`,
+			expected: []Token{
+				{Type: Component, Value: "Code"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Source"},
+				{Type: Colon, Value: ":"},
+				{Type: Illegal, Value: `unterminated raw string, expected closing """`},
+				{Type: EOF},
+			},
+		},
+		{
+			name: "folded",
+			input: `
+\Card
+	Text: >
+		A long sentence
+		on two lines.
+
+		Next: paragraph
+	Items:
+		- >
+			folded item
+	!Wide`,
+			expected: []Token{
+				{Type: Component, Value: "Card"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Text"},
+				{Type: Colon, Value: ":"},
+				{Type: Folded, Value: "A long sentence on two lines.\nNext: paragraph"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Property, Value: "Items"},
+				{Type: Colon, Value: ":"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Indent, Value: "\t"},
+				{Type: Dash, Value: "-"},
+				{Type: Folded, Value: "folded item"},
+				{Type: LineBreak, Value: "\n"},
+				{Type: Indent, Value: "\t"},
+				{Type: Bool, Value: "Wide"},
+				{Type: EOF},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -211,15 +515,17 @@ func TestLineAndColumn(t *testing.T) {
 		tokenType TokenType
 		line      int
 		column    int
+		offset    int
 	}{
-		{Component, 1, 1},
-		{LineBreak, 1, 7},
-		{Indent, 2, 1},
-		{Property, 2, 5},
-		{Colon, 2, 10},
-		{Quote, 2, 12},
-		{Text, 2, 13},
-		{Quote, 2, 18},
+		{Component, 1, 1, 0},
+		{LineBreak, 1, 7, 7},
+		{Indent, 2, 1, 8},
+		{Property, 2, 5, 12},
+		{Colon, 2, 10, 17},
+		{Quote, 2, 12, 19},
+		{Text, 2, 13, 20},
+		{Quote, 2, 18, 25},
+		{EOF, 2, 19, 26},
 	}
 
 	for _, exp := range expected {
@@ -233,5 +539,26 @@ func TestLineAndColumn(t *testing.T) {
 		if token.Column != exp.column {
 			t.Errorf("wrong column number for %v, got %d want %d", token.Type, token.Column, exp.column)
 		}
+		if token.Offset != exp.offset {
+			t.Errorf("wrong offset for %v, got %d want %d", token.Type, token.Offset, exp.offset)
+		}
+	}
+}
+
+func TestHasComments(t *testing.T) {
+	tests := map[string]bool{
+		"\\Card\n\t// Title: \"Draft\"":                     true,
+		"/* \\Section */\n\\Card":                           true,
+		"\\Card\n\tSource: \"\"\"\n\t\t// kept\n\t\t\"\"\"": false,
+		"\\Card\n\tHref: \"//example.com\"":                 false,
+		"/* unterminated":                                   false,
+	}
+	for input, expected := range tests {
+		l := New(input)
+		for l.Next().Type != EOF {
+		}
+		if got := l.HasComments(); got != expected {
+			t.Errorf("HasComments() of %q = %v, want %v", input, got, expected)
+		}
 	}
 }
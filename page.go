@@ -0,0 +1,238 @@
+package margo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/iota-uz/margo/parser"
+	"github.com/yuin/goldmark"
+	meta "github.com/yuin/goldmark-meta"
+	goldmarkparser "github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"gopkg.in/yaml.v2"
+)
+
+// Block types of a Page.
+const (
+	BlockMarkdown = "markdown"
+	BlockMargo    = "margo"
+)
+
+// Page is a markdown page split into its front-matter, markdown and margo blocks,
+// so that its content can be consumed without rendering it.
+//
+//	{"meta": {"title": "Home"}, "blocks": [
+//		{"type": "markdown", "line": 5, "source": "# Welcome"},
+//		{"type": "margo", "line": 7, "nodes": [{"type": "component", "name": "Hero", ...}]}
+//	]}
+type Page struct {
+	Meta   map[string]any `json:"meta,omitempty"`
+	Blocks []*Block       `json:"blocks"`
+}
+
+// Block is a part of a page. Markdown between margo blocks forms a single block.
+type Block struct {
+	Type string `json:"type"`
+	// Line is the line of the page the block starts at.
+	Line int `json:"line,omitempty"`
+	// Source is the markdown of a markdown block.
+	Source string `json:"source,omitempty"`
+	// Nodes are the nodes of a margo block.
+	Nodes []parser.Node `json:"nodes,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Block) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Type   string          `json:"type"`
+		Line   int             `json:"line"`
+		Source string          `json:"source"`
+		Nodes  json.RawMessage `json:"nodes"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*b = Block{Type: v.Type, Line: v.Line, Source: v.Source}
+	switch v.Type {
+	case BlockMarkdown:
+		return nil
+	case BlockMargo:
+		if len(v.Nodes) == 0 {
+			return nil
+		}
+		nodes, err := parser.UnmarshalNodes(v.Nodes)
+		if err != nil {
+			return err
+		}
+		b.Nodes = nodes
+		return nil
+	default:
+		return fmt.Errorf("unknown block type %q", v.Type)
+	}
+}
+
+// ExportPage splits the markdown source of a page into a Page.
+// Margo blocks nested in other markdown blocks, such as lists, are kept as markdown.
+// Syntax errors in margo blocks are returned as parser.Diagnostics.
+func ExportPage(source []byte, path string) (*Page, error) {
+	md := goldmark.New(goldmark.WithExtensions(meta.Meta, Extension(nil)))
+	pc := goldmarkparser.NewContext()
+	pc.Set(parser.FileKey, path)
+	doc := md.Parser().Parse(text.NewReader(source), goldmarkparser.WithContext(pc))
+	m, err := meta.TryGet(pc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse front-matter: %w", err)
+	}
+
+	page := &Page{Blocks: []*Block{}}
+	if len(m) > 0 {
+		page.Meta = jsonValue(m).(map[string]any)
+	}
+	start := 0
+	if m != nil {
+		start = frontMatterEnd(source)
+	}
+	addMarkdown := func(end int) {
+		chunk := source[start:end]
+		trimmed := bytes.TrimLeft(chunk, "\r\n")
+		if len(bytes.TrimSpace(trimmed)) == 0 {
+			return
+		}
+		offset := start + len(chunk) - len(trimmed)
+		page.Blocks = append(page.Blocks, &Block{
+			Type:   BlockMarkdown,
+			Line:   bytes.Count(source[:offset], []byte("\n")) + 1,
+			Source: string(bytes.TrimRight(trimmed, " \t\r\n")),
+		})
+	}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		block, ok := n.(*parser.Document)
+		if !ok {
+			continue
+		}
+		if block.Err != nil {
+			return nil, block.Err
+		}
+		fence := lineOffset(source, block.Line)
+		addMarkdown(fence)
+		page.Blocks = append(page.Blocks, &Block{
+			Type:  BlockMargo,
+			Line:  block.Line,
+			Nodes: block.Children,
+		})
+		start = margoBlockEnd(source, fence, block)
+	}
+	addMarkdown(len(source))
+	return page, nil
+}
+
+// Source turns the page back into markdown, margo blocks being printed
+// in canonical form, see parser.Print.
+func (p *Page) Source() ([]byte, error) {
+	var b bytes.Buffer
+	if len(p.Meta) > 0 {
+		front, err := yaml.Marshal(p.Meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode front-matter: %w", err)
+		}
+		b.WriteString("---\n")
+		b.Write(front)
+		b.WriteString("---\n")
+	}
+	for i, block := range p.Blocks {
+		if i > 0 || b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		switch block.Type {
+		case BlockMarkdown:
+			b.WriteString(block.Source)
+			b.WriteString("\n")
+		case BlockMargo:
+			nodes, err := parser.Print(block.Nodes)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString("```margo\n")
+			b.WriteString(nodes)
+			b.WriteString("```\n")
+		default:
+			return nil, fmt.Errorf("unknown block type %q", block.Type)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// lineOffset returns the offset of the 1-based line in source.
+func lineOffset(source []byte, line int) int {
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := bytes.IndexByte(source[offset:], '\n')
+		if next == -1 {
+			return len(source)
+		}
+		offset += next + 1
+	}
+	return offset
+}
+
+// lineEnd returns the offset following the line holding offset, new line included.
+func lineEnd(source []byte, offset int) int {
+	next := bytes.IndexByte(source[offset:], '\n')
+	if next == -1 {
+		return len(source)
+	}
+	return offset + next + 1
+}
+
+// margoBlockEnd returns the offset following the closing fence of a margo block.
+func margoBlockEnd(source []byte, fence int, block *parser.Document) int {
+	end := lineEnd(source, fence)
+	if lines := block.Lines(); lines.Len() > 0 {
+		end = lineEnd(source, lines.At(lines.Len()-1).Start)
+	}
+	if bytes.HasPrefix(source[end:], []byte("```")) {
+		end = lineEnd(source, end)
+	}
+	return end
+}
+
+// frontMatterEnd returns the offset following the front-matter, read the way goldmark-meta does.
+func frontMatterEnd(source []byte) int {
+	offset := lineEnd(source, 0)
+	for offset < len(source) {
+		end := lineEnd(source, offset)
+		line := util.TrimRightSpace(util.TrimLeftSpace(source[offset:end]))
+		if len(line) > 0 && len(bytes.Trim(line, "-")) == 0 {
+			return end
+		}
+		offset = end
+	}
+	return len(source)
+}
+
+// jsonValue converts the maps decoded from YAML, keyed by any, to maps keyed by string.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, value := range v {
+			m[k] = jsonValue(value)
+		}
+		return m
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, value := range v {
+			m[fmt.Sprint(k)] = jsonValue(value)
+		}
+		return m
+	case []any:
+		list := make([]any, 0, len(v))
+		for _, item := range v {
+			list = append(list, jsonValue(item))
+		}
+		return list
+	default:
+		return v
+	}
+}
@@ -0,0 +1,72 @@
+package margo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/iota-uz/margo/parser"
+	"reflect"
+	"testing"
+)
+
+func TestExportPage(t *testing.T) {
+	source := "---\ntitle: Home\nauthor:\n  name: Jane\n---\n\n# Welcome\n\nSome *text*.\n\n" +
+		"```margo\n\\Hero\n    Title: {{ page.title }}\n    Count: 3\n    See the demo\n```\n" +
+		"````markdown\n```margo\n\\Example\n```\n````\n"
+
+	page, err := ExportPage([]byte(source), "index.md")
+	if err != nil {
+		t.Fatalf("ExportPage() failed: %v", err)
+	}
+	if diff := cmp.Diff(map[string]any{"title": "Home", "author": map[string]any{"name": "Jane"}}, page.Meta); diff != "" {
+		t.Errorf("Meta mismatch (-want +got):\n%s", diff)
+	}
+	if len(page.Blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %d", len(page.Blocks))
+	}
+	if b := page.Blocks[0]; b.Type != BlockMarkdown || b.Line != 7 || b.Source != "# Welcome\n\nSome *text*." {
+		t.Errorf("unexpected first block %+v", b)
+	}
+	if b := page.Blocks[1]; b.Type != BlockMargo || b.Line != 11 || len(b.Nodes) != 1 {
+		t.Errorf("unexpected margo block %+v", b)
+	}
+	if b := page.Blocks[2]; b.Type != BlockMarkdown || b.Source != "````markdown\n```margo\n\\Example\n```\n````" {
+		t.Errorf("unexpected last block %+v", b)
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	var decoded Page
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	opts := cmp.Options{
+		cmp.Exporter(func(reflect.Type) bool { return true }),
+		cmpopts.EquateEmpty(),
+	}
+	if diff := cmp.Diff(page, &decoded, opts); diff != "" {
+		t.Errorf("JSON round trip mismatch (-want +got):\n%s", diff)
+	}
+
+	out, err := decoded.Source()
+	if err != nil {
+		t.Fatalf("Source() failed: %v", err)
+	}
+	expected := "---\nauthor:\n  name: Jane\ntitle: Home\n---\n\n# Welcome\n\nSome *text*.\n\n" +
+		"```margo\n\\Hero\n    Count: 3\n    Title: {{ page.title }}\n    See the demo\n```\n\n" +
+		"````markdown\n```margo\n\\Example\n```\n````\n"
+	if diff := cmp.Diff(expected, string(out)); diff != "" {
+		t.Errorf("Source() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExportPageDiagnostics(t *testing.T) {
+	_, err := ExportPage([]byte("```margo\n\\Hero\n    Title \"x\"\n    Count: x\n```\n"), "index.md")
+	var diags parser.Diagnostics
+	if !errors.As(err, &diags) || diags[0].File != "index.md" {
+		t.Errorf("expected diagnostics for index.md, got %v", err)
+	}
+}
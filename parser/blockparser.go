@@ -7,6 +7,10 @@ import (
 	"github.com/yuin/goldmark/text"
 )
 
+// FileKey is the goldmark parser context key holding the path of the parsed file,
+// reported in the diagnostics of margo blocks.
+var FileKey = parser.NewContextKey()
+
 func BlockParser() parser.BlockParser {
 	return &blockParser{}
 }
@@ -18,11 +22,15 @@ func (m *blockParser) Trigger() []byte {
 }
 
 func (m *blockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
-	line, _ := reader.PeekLine()
+	line, segment := reader.PeekLine()
 	if !bytes.HasPrefix(line, m.Trigger()) {
 		return nil, parser.NoChildren
 	}
-	node := &Document{}
+	before := reader.Source()[:segment.Start]
+	node := &Document{
+		Line:   bytes.Count(before, []byte("\n")) + 1,
+		Column: len(before) - bytes.LastIndexByte(before, '\n'),
+	}
 	return node, parser.NoChildren
 }
 
@@ -44,11 +52,19 @@ func (m *blockParser) Close(node ast.Node, reader text.Reader, pc parser.Context
 		segment := lines.At(i)
 		buf.Write(segment.Value(reader.Source()))
 	}
-	nodes, err := NewMargoParser(string(buf.Bytes())).Parse()
+	n := node.(*Document)
+	file, _ := pc.Get(FileKey).(string)
+	nodes, err := NewMargoParser(
+		string(buf.Bytes()),
+		WithFile(file),
+		WithSegments(reader.Source(), lines),
+		WithRecovery(),
+	).Parse()
 	if err != nil {
-		panic(err)
+		// goldmark has no way to report errors, the renderer surfaces it instead
+		n.Err = err
+		return
 	}
-	n := node.(*Document)
 	n.Children = nodes
 }
 
@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	return [...]string{
+		"error",
+		"warning",
+	}[s]
+}
+
+// Diagnostic codes reported by the parser.
+const (
+	CodeUnexpectedToken = "unexpected-token"
+	CodeInvalidIndent   = "invalid-indent"
+	CodeExpectedQuote   = "expected-quote"
+	CodeExpectedColon   = "expected-colon"
+	CodeExpectedValue   = "expected-value"
+	CodeMalformedString = "malformed-string"
+	CodeInvalidNumber   = "invalid-number"
+	CodeMixedValue      = "mixed-value"
+	CodeInvalidExpr     = "invalid-expr"
+	CodeInvalidEscape   = "invalid-escape"
+)
+
+// Diagnostic is a problem found in a margo block, positioned in the markdown file.
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Severity Severity
+	Code     string
+	Message  string
+	// Source is the line of the file the diagnostic points at.
+	Source string
+}
+
+// Error formats the diagnostic on a single line.
+// Ex.: "docs/intro.md:12:5: error[expected-colon]: expected colon after property name got Title"
+func (d *Diagnostic) Error() string {
+	var b strings.Builder
+	if d.File != "" {
+		b.WriteString(d.File)
+		b.WriteString(":")
+	}
+	fmt.Fprintf(&b, "%d:%d: %s[%s]: %s", d.Line, d.Column, d.Severity, d.Code, d.Message)
+	return b.String()
+}
+
+// Pretty formats the diagnostic followed by an excerpt of the source with a caret
+// under the offending column.
+//
+//	docs/intro.md:12:5: error[expected-colon]: expected colon after property name got Title
+//	   |
+//	12 |     Title "Hello"
+//	   |           ^
+func (d *Diagnostic) Pretty() string {
+	var b strings.Builder
+	b.WriteString(d.Error())
+	if d.Source == "" {
+		return b.String()
+	}
+	line := strconv.Itoa(d.Line)
+	gutter := strings.Repeat(" ", len(line))
+	fmt.Fprintf(&b, "\n%s |\n%s | %s\n%s | ", gutter, line, d.Source, gutter)
+	for i := 0; i < d.Column-1 && i < len(d.Source); i++ {
+		// keep tabs so that the caret lines up with the excerpt
+		if d.Source[i] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString("^")
+	return b.String()
+}
+
+// Diagnostics is the list of every problem found in a margo block.
+type Diagnostics []*Diagnostic
+
+func (d Diagnostics) Error() string {
+	msgs := make([]string, 0, len(d))
+	for _, diag := range d {
+		msgs = append(msgs, diag.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Pretty formats every diagnostic with its source excerpt.
+func (d Diagnostics) Pretty() string {
+	msgs := make([]string, 0, len(d))
+	for _, diag := range d {
+		msgs = append(msgs, diag.Pretty())
+	}
+	return strings.Join(msgs, "\n\n")
+}
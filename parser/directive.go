@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var KindDirective = ast.NewNodeKind("MargoDirective")
+
+// Directive is a component used inline in markdown text.
+// The label becomes the children of the component, the attributes its props.
+// Ex.: :Badge[New]{color="red"}
+type Directive struct {
+	ast.BaseInline
+	Name string
+}
+
+// Kind implements Node.Kind.
+func (n *Directive) Kind() ast.NodeKind {
+	return KindDirective
+}
+
+// Dump implements Node.Dump
+func (n *Directive) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.Name}, nil)
+}
+
+var KindDirectiveBlock = ast.NewNodeKind("MargoDirectiveBlock")
+
+// DirectiveBlock is a component wrapping markdown content.
+// The content becomes the children of the component, the attributes its props.
+// The closing fence must be at least as long as the opening one, so containers nest
+// by using longer fences for the outer ones.
+//
+//	:::Callout{type="warning"}
+//	Markdown **content**
+//	:::
+type DirectiveBlock struct {
+	ast.BaseBlock
+	Name  string
+	fence int
+}
+
+// Kind implements Node.Kind.
+func (n *DirectiveBlock) Kind() ast.NodeKind {
+	return KindDirectiveBlock
+}
+
+// Dump implements Node.Dump
+func (n *DirectiveBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.Name}, nil)
+}
+
+// scanName returns the length of the component name line starts with.
+// Names start with an upper case letter. Ex.: "Badge[New]" -> 5
+func scanName(line []byte) int {
+	if len(line) == 0 || line[0] < 'A' || line[0] > 'Z' {
+		return 0
+	}
+	i := 1
+	for i < len(line) && (util.IsAlphaNumeric(line[i]) || line[i] == '_') {
+		i++
+	}
+	return i
+}
+
+// setAttributes parses the {key=value} attributes reader is at into node.
+func setAttributes(node ast.Node, reader text.Reader) bool {
+	attrs, ok := parser.ParseAttributes(reader)
+	if !ok {
+		return false
+	}
+	for _, attr := range attrs {
+		node.SetAttribute(attr.Name, attr.Value)
+	}
+	return true
+}
+
+func newlineLen(line []byte) int {
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		return 1
+	}
+	return 0
+}
+
+func InlineParser() parser.InlineParser {
+	return &inlineParser{}
+}
+
+type inlineParser struct{}
+
+func (p *inlineParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (p *inlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	// a directive starts a word, so that "12:30" or "Note:Badge" stay text
+	if prev := block.PrecendingCharacter(); prev != '\n' && !util.IsSpace(byte(prev)) && !util.IsPunct(byte(prev)) {
+		return nil
+	}
+	line, segment := block.PeekLine()
+	n := scanName(line[1:])
+	if n == 0 {
+		return nil
+	}
+	rest := line[1+n:]
+	if len(rest) == 0 || (rest[0] != '[' && rest[0] != '{') {
+		return nil
+	}
+	node := &Directive{Name: string(line[1 : 1+n])}
+	consumed := 1 + n
+	if rest[0] == '[' {
+		end := bytes.IndexByte(rest, ']')
+		if end == -1 {
+			return nil
+		}
+		start := segment.Start + consumed + 1
+		node.AppendChild(node, ast.NewTextSegment(text.NewSegment(start, start+end-1)))
+		consumed += end + 1
+	}
+	block.Advance(consumed)
+	if rest := line[consumed:]; len(rest) > 0 && rest[0] == '{' {
+		// an invalid attribute list is left as text
+		_ = setAttributes(node, block)
+	}
+	return node
+}
+
+func DirectiveBlockParser() parser.BlockParser {
+	return &directiveBlockParser{}
+}
+
+type directiveBlockParser struct{}
+
+func (p *directiveBlockParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (p *directiveBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 {
+		return nil, parser.NoChildren
+	}
+	line = line[pos:]
+	fence := 0
+	for fence < len(line) && line[fence] == ':' {
+		fence++
+	}
+	if fence < 3 {
+		return nil, parser.NoChildren
+	}
+	n := scanName(line[fence:])
+	if n == 0 {
+		return nil, parser.NoChildren
+	}
+	node := &DirectiveBlock{Name: string(line[fence : fence+n]), fence: fence}
+	rest := line[fence+n:]
+	if len(rest) > 0 && rest[0] == '{' {
+		attrs := text.NewReader(rest)
+		if !setAttributes(node, attrs) {
+			return nil, parser.NoChildren
+		}
+		_, pos := attrs.Position()
+		rest = rest[pos.Start:]
+	}
+	if !util.IsBlank(rest) {
+		return nil, parser.NoChildren
+	}
+	// the content starts on the next line
+	reader.Advance(segment.Len() - newlineLen(line) + segment.Padding)
+	return node, parser.HasChildren
+}
+
+func (p *directiveBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	trimmed := util.TrimRightSpace(util.TrimLeftSpace(line))
+	if len(trimmed) >= node.(*DirectiveBlock).fence && len(bytes.Trim(trimmed, ":")) == 0 {
+		reader.Advance(segment.Len() - newlineLen(line) + segment.Padding)
+		return parser.Close
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+func (p *directiveBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	// do nothing
+}
+
+func (p *directiveBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *directiveBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Expr references a variable resolved at render time, such as the front-matter
+// of the page or the site configuration.
+// Ex.: {{ page.title }}, {{ site.name }}
+type Expr struct {
+	Path []string
+	Span Span
+}
+
+func (e *Expr) String() string {
+	return "{{ " + strings.Join(e.Path, ".") + " }}"
+}
+
+// ParseExpr parses an expression, with or without its braces.
+func ParseExpr(s string) (*Expr, error) {
+	inner := strings.TrimSpace(s)
+	if strings.HasPrefix(inner, "{{") && strings.HasSuffix(inner, "}}") {
+		inner = strings.TrimSpace(inner[2 : len(inner)-2])
+	}
+	if inner == "" {
+		return nil, fmt.Errorf("empty expression %q", s)
+	}
+	path := strings.Split(inner, ".")
+	for _, name := range path {
+		if !isIdentifier(name) {
+			return nil, fmt.Errorf("invalid expression %q, expected a dotted path such as page.title", s)
+		}
+	}
+	return &Expr{Path: path}, nil
+}
+
+// SkipExpr is returned by the resolve function of Interpolate to keep an expression as written.
+var SkipExpr = errors.New("skip expression")
+
+// Interpolate replaces every expression in s with its value.
+// Braces that do not form an expression, and expressions for which resolve
+// returns SkipExpr, are kept as is.
+func Interpolate(s string, resolve func(*Expr) (any, error)) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "{{")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(s[start:], "}}")
+		if end == -1 {
+			break
+		}
+		end += start + 2
+		expr, err := ParseExpr(s[start:end])
+		if err != nil {
+			// not an expression, such as a Go template in a code sample
+			b.WriteString(s[:end])
+			s = s[end:]
+			continue
+		}
+		v, err := resolve(expr)
+		if errors.Is(err, SkipExpr) {
+			b.WriteString(s[:end])
+			s = s[end:]
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s[:start])
+		if v != nil {
+			fmt.Fprint(&b, v)
+		}
+		s = s[end:]
+	}
+	b.WriteString(s)
+	return b.String(), nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch != '_' && ch != '-' && !(ch >= 'a' && ch <= 'z') && !(ch >= 'A' && ch <= 'Z') && !(ch >= '0' && ch <= '9') {
+			return false
+		}
+	}
+	return true
+}
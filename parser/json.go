@@ -0,0 +1,298 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Node types of the JSON representation.
+const (
+	nodeComponent = "component"
+	nodeText      = "text"
+)
+
+// Value types of the JSON representation.
+const (
+	valueNull      = "null"
+	valueString    = "string"
+	valueRaw       = "raw"
+	valueBool      = "bool"
+	valueInt       = "int"
+	valueFloat     = "float"
+	valueExpr      = "expr"
+	valueList      = "list"
+	valueObject    = "object"
+	valueComponent = "component"
+)
+
+// nodeJSON is the JSON representation of a node.
+//
+//	{"type": "component", "name": "Hero", "props": [...], "children": [...], "span": {...}}
+//	{"type": "text", "value": "See the demo", "span": {...}}
+type nodeJSON struct {
+	Type     string            `json:"type"`
+	Name     string            `json:"name,omitempty"`
+	Value    string            `json:"value,omitempty"`
+	Props    []*Attribute      `json:"props,omitempty"`
+	Children []json.RawMessage `json:"children,omitempty"`
+	Span     Span              `json:"span"`
+}
+
+// valueJSON is the JSON representation of a property value, typed so that
+// ints, floats and expressions are decoded back as such.
+//
+//	{"type": "int", "value": 3}
+//	{"type": "expr", "value": "page.title", "span": {...}}
+type valueJSON struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Span  *Span           `json:"span,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n *ComponentNode) MarshalJSON() ([]byte, error) {
+	children := make([]json.RawMessage, 0, len(n.children))
+	for _, child := range n.children {
+		data, err := json.Marshal(child)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, data)
+	}
+	return json.Marshal(nodeJSON{
+		Type:     nodeComponent,
+		Name:     n.Name,
+		Props:    n.properties,
+		Children: children,
+		Span:     n.span,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *ComponentNode) UnmarshalJSON(data []byte) error {
+	var v nodeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Type != nodeComponent {
+		return fmt.Errorf("expected a %s node got %q", nodeComponent, v.Type)
+	}
+	*n = ComponentNode{Name: v.Name, span: v.Span}
+	for _, attr := range v.Props {
+		n.addAttribute(attr)
+	}
+	for _, raw := range v.Children {
+		child, err := UnmarshalNode(raw)
+		if err != nil {
+			return err
+		}
+		n.children = append(n.children, child)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t *TextNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(nodeJSON{
+		Type:  nodeText,
+		Value: t.Value,
+		Span:  t.span,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *TextNode) UnmarshalJSON(data []byte) error {
+	var v nodeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Type != nodeText {
+		return fmt.Errorf("expected a %s node got %q", nodeText, v.Type)
+	}
+	*t = TextNode{Value: v.Value, span: v.Span}
+	return nil
+}
+
+// UnmarshalNode decodes a node encoded with json.Marshal.
+func UnmarshalNode(data []byte) (Node, error) {
+	var v struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var node interface {
+		Node
+		json.Unmarshaler
+	}
+	switch v.Type {
+	case nodeComponent:
+		node = &ComponentNode{}
+	case nodeText:
+		node = &TextNode{}
+	default:
+		return nil, fmt.Errorf("unknown node type %q", v.Type)
+	}
+	if err := node.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// UnmarshalNodes decodes a JSON array of nodes, such as json.Marshal([]Node).
+func UnmarshalNodes(data []byte) ([]Node, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, err
+	}
+	nodes := make([]Node, 0, len(raws))
+	for _, raw := range raws {
+		node, err := UnmarshalNode(raw)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a *Attribute) MarshalJSON() ([]byte, error) {
+	value, err := marshalValue(a.Value)
+	if err != nil {
+		return nil, fmt.Errorf("property %s: %w", a.Name, err)
+	}
+	return json.Marshal(struct {
+		Name  string    `json:"name"`
+		Value valueJSON `json:"value"`
+		Span  Span      `json:"span"`
+	}{a.Name, value, a.Span})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *Attribute) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Name  string    `json:"name"`
+		Value valueJSON `json:"value"`
+		Span  Span      `json:"span"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	value, err := unmarshalValue(v.Value)
+	if err != nil {
+		return fmt.Errorf("property %s: %w", v.Name, err)
+	}
+	*a = Attribute{Name: v.Name, Value: value, Span: v.Span}
+	return nil
+}
+
+func marshalValue(v any) (valueJSON, error) {
+	var typ string
+	var span *Span
+	switch value := v.(type) {
+	case nil:
+		return valueJSON{Type: valueNull}, nil
+	case string:
+		typ = valueString
+	case RawString:
+		typ = valueRaw
+	case bool:
+		typ = valueBool
+	case int64:
+		typ = valueInt
+	case float64:
+		typ = valueFloat
+	case *Expr:
+		typ, span = valueExpr, &value.Span
+		v = strings.Join(value.Path, ".")
+	case List:
+		typ = valueList
+		items := make([]valueJSON, 0, len(value))
+		for _, item := range value {
+			data, err := marshalValue(item)
+			if err != nil {
+				return valueJSON{}, err
+			}
+			items = append(items, data)
+		}
+		v = items
+	case Object:
+		typ = valueObject
+	case *ComponentNode:
+		typ = valueComponent
+	default:
+		return valueJSON{}, fmt.Errorf("cannot encode value of type %T", v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return valueJSON{}, err
+	}
+	return valueJSON{Type: typ, Value: data, Span: span}, nil
+}
+
+func unmarshalValue(v valueJSON) (any, error) {
+	var err error
+	switch v.Type {
+	case valueNull:
+		return nil, nil
+	case valueString:
+		var s string
+		err = json.Unmarshal(v.Value, &s)
+		return s, err
+	case valueRaw:
+		var s string
+		err = json.Unmarshal(v.Value, &s)
+		return RawString(s), err
+	case valueBool:
+		var b bool
+		err = json.Unmarshal(v.Value, &b)
+		return b, err
+	case valueInt:
+		var i int64
+		err = json.Unmarshal(v.Value, &i)
+		return i, err
+	case valueFloat:
+		var f float64
+		err = json.Unmarshal(v.Value, &f)
+		return f, err
+	case valueExpr:
+		var s string
+		if err := json.Unmarshal(v.Value, &s); err != nil {
+			return nil, err
+		}
+		expr, err := ParseExpr(s)
+		if err != nil {
+			return nil, err
+		}
+		if v.Span != nil {
+			expr.Span = *v.Span
+		}
+		return expr, nil
+	case valueList:
+		var items []valueJSON
+		if err := json.Unmarshal(v.Value, &items); err != nil {
+			return nil, err
+		}
+		list := make(List, 0, len(items))
+		for _, item := range items {
+			value, err := unmarshalValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case valueObject:
+		var object Object
+		err = json.Unmarshal(v.Value, &object)
+		return object, err
+	case valueComponent:
+		node := &ComponentNode{}
+		err = json.Unmarshal(v.Value, node)
+		return node, err
+	default:
+		return nil, fmt.Errorf("unknown value type %q", v.Type)
+	}
+}
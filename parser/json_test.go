@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNodesJSON(t *testing.T) {
+	input := `\PricingTable
+    Title: {{ page.title }}
+    Count: 3
+    Ratio: 0.5
+    Image: null
+    !Wide
+    Features:
+        - "Fast"
+        - Name: "Jane"
+    Icon:
+        \Icon
+            Size: 24
+    Pick a plan`
+
+	nodes, err := NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	for _, expected := range []string{
+		`"type":"component","name":"PricingTable"`,
+		`"value":{"type":"expr","value":"page.title"`,
+		`"value":{"type":"int","value":3}`,
+		`"value":{"type":"float","value":0.5}`,
+		`"value":{"type":"null"}`,
+		`"type":"text","value":"Pick a plan","span":{"start":{"offset":199,"line":13,"column":5}`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected JSON to contain %s, got %s", expected, data)
+		}
+	}
+
+	decoded, err := UnmarshalNodes(data)
+	if err != nil {
+		t.Fatalf("UnmarshalNodes() failed: %v", err)
+	}
+	opts := cmp.Options{
+		cmp.Exporter(func(reflect.Type) bool { return true }),
+		cmpopts.EquateEmpty(),
+	}
+	if diff := cmp.Diff(nodes, decoded, opts); diff != "" {
+		t.Errorf("JSON round trip mismatch (-want +got):\n%s", diff)
+	}
+
+	raw, err := NewMargoParser(`\Code Source: """{{ name }}"""`).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if data, err = json.Marshal(raw); err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	if expected := `"value":{"type":"raw","value":"{{ name }}"}`; !strings.Contains(string(data), expected) {
+		t.Errorf("expected JSON to contain %s, got %s", expected, data)
+	}
+	if decoded, err = UnmarshalNodes(data); err != nil {
+		t.Fatalf("UnmarshalNodes() failed: %v", err)
+	}
+	if diff := cmp.Diff(raw, decoded, opts); diff != "" {
+		t.Errorf("JSON round trip mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestUnmarshalNodesErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown node":  `[{"type":"image"}]`,
+		"unknown value": `[{"type":"component","name":"A","props":[{"name":"B","value":{"type":"date"}}]}]`,
+		"invalid expr":  `[{"type":"component","name":"A","props":[{"name":"B","value":{"type":"expr","value":"a b"}}]}]`,
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := UnmarshalNodes([]byte(input)); err == nil {
+				t.Errorf("expected an error decoding %s", input)
+			}
+		})
+	}
+}
@@ -6,33 +6,106 @@ type Props map[string]any
 
 type Node interface {
 	Children() []Node
+	// Span returns the range of source the node was parsed from.
+	Span() Span
+}
+
+// Attribute is a property of a component.
+// Ex.: `Title: "Hello"` or `!Visible`
+type Attribute struct {
+	Name  string
+	Value any
+	// Span covers the attribute from its name to the end of its value.
+	Span Span
+}
+
+// RawString is a property value written with triple quotes, kept exactly as written:
+// expressions in it are not interpolated.
+// Ex.:
+//
+//	Source: """
+//	    Hello {{ name }}
+//	    """
+type RawString string
+
+// List is a property value made of several items.
+// Ex.:
+//
+//	Items:
+//	    - "Fast"
+//	    - "Secure"
+type List []any
+
+// Object is a property value made of nested properties.
+// Ex.:
+//
+//	Author:
+//	    Name: "Jane"
+//	    Url: "https://example.com"
+type Object []*Attribute
+
+// Get returns the property with the given name.
+func (o Object) Get(name string) (*Attribute, bool) {
+	for _, attr := range o {
+		if attr.Name == name {
+			return attr, true
+		}
+	}
+	return nil, false
 }
 
 type ComponentNode struct {
 	attributes []ast.Attribute
+	properties []*Attribute
 	Name       string
 	children   []Node
+	span       Span
 }
 
 func (n *ComponentNode) Attributes() []ast.Attribute {
 	return n.attributes
 }
 
+// Properties returns the attributes of the component along with their position.
+func (n *ComponentNode) Properties() []*Attribute {
+	return n.properties
+}
+
+func (n *ComponentNode) addAttribute(attr *Attribute) {
+	n.properties = append(n.properties, attr)
+	n.attributes = append(n.attributes, ast.Attribute{
+		Name:  []byte(attr.Name),
+		Value: attr.Value,
+	})
+}
+
 func (n *ComponentNode) Children() []Node {
 	return n.children
 }
 
+func (n *ComponentNode) Span() Span {
+	return n.span
+}
+
 type TextNode struct {
 	Value string
+	span  Span
 }
 
 func (t *TextNode) Children() []Node { return nil }
 
+func (t *TextNode) Span() Span { return t.span }
+
 var KindMargoNode = ast.NewNodeKind("MargoNode")
 
 type Document struct {
 	ast.BaseBlock
 	Children []Node
+	// Line and Column locate the opening fence of the block in the markdown source.
+	Line   int
+	Column int
+	// Err is set when the content of the block could not be parsed.
+	Err error
 }
 
 // Kind implements Node.Kind.
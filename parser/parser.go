@@ -1,26 +1,184 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"github.com/iota-uz/margo/lexer"
 	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"strconv"
 	"strings"
 )
 
 type Parser struct {
-	lexer  *lexer.Lexer
-	indent int
+	lexer      *lexer.Lexer
+	indent     int
+	lines      []string
+	file       string
+	lineOffset int
+	sourceMap  *sourceMap
+	recovery   bool
+	lexerOpts  []lexer.Option
+	diags      Diagnostics
+	// end is the position right after the last consumed token
+	end Position
 }
 
-func NewMargoParser(content string) *Parser {
-	return &Parser{
-		lexer:  lexer.New(content),
+// Option configures the parser.
+type Option func(*Parser)
+
+// WithFile sets the file name reported in diagnostics.
+func WithFile(name string) Option {
+	return func(p *Parser) {
+		p.file = name
+	}
+}
+
+// WithLineOffset sets the number of lines preceding the content in its file,
+// so diagnostics are positioned relative to the whole file.
+func WithLineOffset(n int) Option {
+	return func(p *Parser) {
+		p.lineOffset = n
+	}
+}
+
+// WithSegments sets the lines of source the content was read from,
+// so node positions and diagnostics are relative to the whole source.
+// It takes precedence over WithLineOffset.
+func WithSegments(source []byte, lines *text.Segments) Option {
+	return func(p *Parser) {
+		p.sourceMap = newSourceMap(source, lines)
+	}
+}
+
+// WithRecovery makes the parser skip the rest of a line after an error
+// and keep going, so every error of the content is reported at once.
+func WithRecovery() Option {
+	return func(p *Parser) {
+		p.recovery = true
+	}
+}
+
+// WithIndentWidth sets the number of spaces of an indentation level,
+// which is otherwise detected from the content, see lexer.WithIndentWidth.
+func WithIndentWidth(n int) Option {
+	return func(p *Parser) {
+		p.lexerOpts = append(p.lexerOpts, lexer.WithIndentWidth(n))
+	}
+}
+
+func NewMargoParser(content string, opts ...Option) *Parser {
+	p := &Parser{
 		indent: 0,
+		lines:  strings.Split(content, "\n"),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	p.lexer = lexer.New(content, p.lexerOpts...)
+	return p
 }
 
+// Parse parses the content into nodes.
+// Errors are reported as Diagnostics. In recovery mode the nodes that could be
+// parsed are returned alongside the diagnostics.
 func (p *Parser) Parse() ([]Node, error) {
-	return p.parseBlock()
+	nodes, err := p.parseBlock()
+	if err != nil {
+		var diag *Diagnostic
+		if !errors.As(err, &diag) {
+			return nil, err
+		}
+		p.diags = append(p.diags, diag)
+	}
+	if len(p.diags) == 0 {
+		return nodes, nil
+	}
+	if !p.recovery {
+		return nil, p.diags
+	}
+	return nodes, p.diags
+}
+
+// position translates a position in the content to the source.
+func (p *Parser) position(offset, line, column int) Position {
+	if p.sourceMap != nil {
+		return p.sourceMap.position(offset, line)
+	}
+	return Position{
+		Offset: offset,
+		Line:   line + p.lineOffset,
+		Column: column,
+	}
+}
+
+func (p *Parser) tokenPos(token lexer.Token) Position {
+	return p.position(token.Offset, token.Line, token.Column)
+}
+
+// next consumes a token, keeping track of where the last significant token ends.
+func (p *Parser) next() lexer.Token {
+	token := p.lexer.Next()
+	switch token.Type {
+	case lexer.EOF, lexer.LineBreak, lexer.Indent:
+	default:
+		p.end = p.position(p.lexer.Pos())
+	}
+	return token
+}
+
+// errorf creates a diagnostic located at token.
+func (p *Parser) errorf(token lexer.Token, code string, format string, args ...any) *Diagnostic {
+	pos := p.tokenPos(token)
+	diag := &Diagnostic{
+		File:     p.file,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Severity: SeverityError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if p.sourceMap != nil {
+		diag.Source = p.sourceMap.lineText(token.Line)
+	} else if token.Line > 0 && token.Line <= len(p.lines) {
+		diag.Source = p.lines[token.Line-1]
+	}
+	return diag
+}
+
+// unexpected reports a token that is not allowed where it was found.
+func (p *Parser) unexpected(token lexer.Token) *Diagnostic {
+	if token.Type == lexer.Illegal {
+		return p.errorf(token, CodeInvalidIndent, "%s", token.Value)
+	}
+	return p.errorf(token, CodeUnexpectedToken, "unexpected %v %q", token.Type, token.Value)
+}
+
+// recoverFrom records err and skips the rest of the line when the parser runs in
+// recovery mode, otherwise err is returned as is.
+func (p *Parser) recoverFrom(err error) error {
+	var diag *Diagnostic
+	if !p.recovery || !errors.As(err, &diag) {
+		return err
+	}
+	p.diags = append(p.diags, diag)
+	for token := p.lexer.Peek(); token.Type != lexer.EOF && token.Type != lexer.LineBreak; token = p.lexer.Peek() {
+		p.next()
+	}
+	return nil
+}
+
+// invalidIndent reports an invalid indent and counts it as one level,
+// so that the rest of the line is still parsed in recovery mode.
+func (p *Parser) invalidIndent(token lexer.Token) error {
+	p.next()
+	p.indent++
+	diag := p.unexpected(token)
+	if !p.recovery {
+		return diag
+	}
+	p.diags = append(p.diags, diag)
+	return nil
 }
 
 func (p *Parser) parseBlock() ([]Node, error) {
@@ -30,12 +188,18 @@ func (p *Parser) parseBlock() ([]Node, error) {
 	for token := p.lexer.Peek(); token.Type != lexer.EOF; token = p.lexer.Peek() {
 		if token.Type == lexer.Indent {
 			p.indent++
-			p.lexer.Next()
+			p.next()
 			continue
 		}
 		if token.Type == lexer.LineBreak {
 			p.indent = 0
-			p.lexer.Next()
+			p.next()
+			continue
+		}
+		if token.Type == lexer.Illegal {
+			if err := p.invalidIndent(token); err != nil {
+				return nil, err
+			}
 			continue
 		}
 		if p.indent <= baseIndent && baseIndent != 0 {
@@ -45,164 +209,319 @@ func (p *Parser) parseBlock() ([]Node, error) {
 		case lexer.Component:
 			node, err := p.parseComponentNode()
 			if err != nil {
-				return nil, err
+				if err := p.recoverFrom(err); err != nil {
+					return nil, err
+				}
+				continue
 			}
 			nodes = append(nodes, node)
 		case lexer.Text:
-			p.lexer.Next()
-			nodes = append(nodes, &TextNode{Value: token.Value})
+			nodes = append(nodes, p.parseTextNode())
 		default:
-			panic(fmt.Sprintf("unexpected %v: %v", token.Type, token.Value))
+			if err := p.recoverFrom(p.unexpected(token)); err != nil {
+				return nil, err
+			}
 		}
 	}
 	return nodes, nil
 }
 
 func (p *Parser) parseString() (string, error) {
-	if token := p.lexer.Next(); token.Type != lexer.Quote {
-		return "", fmt.Errorf(
-			"%d:%d expected opening quote got %v",
-			token.Line,
-			token.Column,
-			token.Value,
-		)
+	if token := p.next(); token.Type != lexer.Quote {
+		return "", p.errorf(token, CodeExpectedQuote, "expected opening quote got %v", token.Value)
+	}
+	token := p.next()
+	if token.Type == lexer.Illegal {
+		return "", p.errorf(token, CodeInvalidEscape, "%s", token.Value)
 	}
-	token := p.lexer.Next()
 	if token.Type != lexer.Text {
-		return "", fmt.Errorf(
-			"malformed string at %d:%d",
-			token.Line,
-			token.Column,
-		)
-	}
-	if next := p.lexer.Next(); next.Type != lexer.Quote {
-		return "", fmt.Errorf(
-			"%d:%d expected closing quote got %v",
-			next.Line,
-			next.Column,
-			next.Value,
-		)
+		return "", p.errorf(token, CodeMalformedString, "malformed string")
+	}
+	if next := p.next(); next.Type != lexer.Quote {
+		return "", p.errorf(next, CodeExpectedQuote, "expected closing quote got %v", next.Value)
 	}
 	return token.Value, nil
 }
 
-func (p *Parser) parseMultiLineString(currentIndent int) (string, error) {
-	var s string
-	indent := currentIndent
-	for token := p.lexer.Next(); token.Type != lexer.EOF; token = p.lexer.Next() {
+// parseNumber parses a number literal into an int64, or a float64 when it has
+// a fraction or an exponent.
+func (p *Parser) parseNumber() (any, error) {
+	token := p.next()
+	if !strings.ContainsAny(token.Value, ".eE") {
+		if i, err := strconv.ParseInt(token.Value, 10, 64); err == nil {
+			return i, nil
+		}
+	}
+	f, err := strconv.ParseFloat(token.Value, 64)
+	if err != nil {
+		return nil, p.errorf(token, CodeInvalidNumber, "invalid number %s", token.Value)
+	}
+	return f, nil
+}
+
+func (p *Parser) parsePropertyValue() (any, error) {
+	next := p.lexer.Peek()
+	switch next.Type {
+	case lexer.Quote:
+		return p.parseString()
+	case lexer.RawString:
+		p.next()
+		return RawString(next.Value), nil
+	case lexer.Folded:
+		p.next()
+		return next.Value, nil
+	case lexer.Illegal:
+		return nil, p.errorf(next, CodeMalformedString, "%s", next.Value)
+	case lexer.Number:
+		return p.parseNumber()
+	case lexer.Boolean:
+		p.next()
+		return next.Value == "true", nil
+	case lexer.Null:
+		p.next()
+		return nil, nil
+	case lexer.Expr:
+		p.next()
+		expr, err := ParseExpr(next.Value)
+		if err != nil {
+			return nil, p.errorf(next, CodeInvalidExpr, "%v", err)
+		}
+		expr.Span = Span{Start: p.tokenPos(next), End: p.end}
+		if after := p.lexer.Peek(); after.Type == lexer.Text {
+			return nil, p.errorf(after, CodeInvalidExpr, "unexpected %q after expression, quote the value to mix text and expressions", after.Value)
+		}
+		return expr, nil
+	case lexer.LineBreak:
+		p.next()
+		return p.parseValueBlock(next)
+	default:
+		return nil, p.errorf(next, CodeExpectedValue, "expected \" or newline got %v", next.Value)
+	}
+}
+
+// parseValueBlock parses the indented lines of a property value.
+// Text lines are joined into a string, a single component is returned as is,
+// several components form a List, as do list items, and properties form an Object.
+func (p *Parser) parseValueBlock(start lexer.Token) (any, error) {
+	var nodes []Node
+	var items List
+	var object Object
+	components := 0
+	baseIndent := p.indent
+	p.indent = 0
+	for token := p.lexer.Peek(); token.Type != lexer.EOF; token = p.lexer.Peek() {
+		if token.Type == lexer.Indent {
+			p.indent++
+			p.next()
+			continue
+		}
 		if token.Type == lexer.LineBreak {
-			indent = 0
+			p.indent = 0
+			p.next()
 			continue
 		}
-		switch token.Type {
-		case lexer.Indent:
-			indent++
+		if token.Type == lexer.Illegal {
+			if err := p.invalidIndent(token); err != nil {
+				return nil, err
+			}
 			continue
+		}
+		if p.indent <= baseIndent {
+			break
+		}
+		switch token.Type {
+		case lexer.Component:
+			node, err := p.parseComponentNode()
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node)
+			components++
 		case lexer.Text:
-			s += token.Value
-			continue
+			nodes = append(nodes, p.parseTextNode())
+		case lexer.Dash:
+			item, err := p.parseListItem()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		case lexer.Property:
+			attr, err := p.parseProperty()
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, attr)
 		default:
-			// do nothing
+			return nil, p.unexpected(token)
 		}
+	}
 
-		if indent <= currentIndent {
-			return "", fmt.Errorf(
-				"%d:%d expected indent greater than %d got %d",
-				token.Column,
-				token.Type,
-				currentIndent,
-				indent,
-			)
+	kinds := 0
+	for _, n := range []int{len(nodes), len(items), len(object)} {
+		if n > 0 {
+			kinds++
 		}
-		if token.Type != lexer.Text {
-			return "", fmt.Errorf(
-				"%d:%d expected string got %v",
-				token.Column,
-				token.Type,
-				token.Value,
-			)
+	}
+	if kinds > 1 {
+		return nil, p.errorf(start, CodeMixedValue, "a property value cannot mix text, components, list items and properties")
+	}
+	switch {
+	case len(items) > 0:
+		return items, nil
+	case len(object) > 0:
+		return object, nil
+	case components > 1:
+		list := make(List, 0, len(nodes))
+		for _, node := range nodes {
+			list = append(list, node)
 		}
-		s += token.Value
+		return list, nil
+	case components == 1:
+		for _, node := range nodes {
+			if c, ok := node.(*ComponentNode); ok {
+				return c, nil
+			}
+		}
+	}
+	var text []string
+	for _, node := range nodes {
+		text = append(text, node.(*TextNode).Value)
 	}
-	return s, nil
+	return strings.Join(text, "\n"), nil
 }
 
-func (p *Parser) parsePropertyValue() (any, error) {
-	next := p.lexer.Peek()
-	if next.Type == lexer.Quote {
-		return p.parseString()
-	}
-	if next.Type == lexer.LineBreak {
-		p.lexer.Next()
-		nodes, err := p.parseBlock()
+// parseListItem parses a list item. An item starting with a property is an Object
+// whose other properties are indented under the dash.
+//
+//   - Name: "Free"
+//     Price: 0
+func (p *Parser) parseListItem() (any, error) {
+	p.next()
+	baseIndent := p.indent
+	switch next := p.lexer.Peek(); next.Type {
+	case lexer.Component:
+		return p.parseComponentNode()
+	case lexer.Property:
+		attr, err := p.parseProperty()
 		if err != nil {
 			return nil, err
 		}
-		componentNodes := 0
-		for _, node := range nodes {
-			if _, ok := node.(*ComponentNode); ok {
-				componentNodes++
+		object := Object{attr}
+		for token := p.lexer.Peek(); token.Type != lexer.EOF; token = p.lexer.Peek() {
+			if token.Type == lexer.Indent {
+				p.indent++
+				p.next()
+				continue
 			}
-		}
-		if componentNodes > 1 {
-			return nil, fmt.Errorf(
-				"%d:%d a property value can only contain one component got %d",
-				next.Line,
-				next.Column,
-				componentNodes,
-			)
-		}
-		var text []string
-		if componentNodes == 0 {
-			for _, node := range nodes {
-				text = append(text, node.(*TextNode).Value)
+			if token.Type == lexer.LineBreak {
+				p.indent = 0
+				p.next()
+				continue
+			}
+			if token.Type == lexer.Illegal {
+				if err := p.invalidIndent(token); err != nil {
+					return nil, err
+				}
+				continue
 			}
-			return strings.Join(text, "\n"), nil
+			if p.indent <= baseIndent {
+				break
+			}
+			if token.Type != lexer.Property {
+				return nil, p.unexpected(token)
+			}
+			attr, err := p.parseProperty()
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, attr)
 		}
-		return nodes[0], nil
+		return object, nil
+	default:
+		return p.parsePropertyValue()
+	}
+}
 
+func (p *Parser) parseTextNode() *TextNode {
+	token := p.next()
+	return &TextNode{
+		Value: token.Value,
+		span:  Span{Start: p.tokenPos(token), End: p.end},
 	}
-	return nil, fmt.Errorf(
-		"%d:%d expected \" or newline got %v",
-		next.Line,
-		next.Column,
-		next.Value,
-	)
 }
 
-func (p *Parser) parseProperty() (string, any, error) {
-	name := p.lexer.Next().Value
-	if next := p.lexer.Next(); next.Type != lexer.Colon {
-		return "", nil, fmt.Errorf(
-			"%d:%d expected colon after property name got %v",
-			next.Line,
-			next.Column,
-			next.Value,
-		)
+func (p *Parser) parseProperty() (*Attribute, error) {
+	name := p.next()
+	if next := p.next(); next.Type != lexer.Colon {
+		return nil, p.errorf(next, CodeExpectedColon, "expected colon after property name got %v", next.Value)
 	}
 	v, err := p.parsePropertyValue()
 	if err != nil {
-		return "", nil, err
+		return nil, err
+	}
+	return &Attribute{
+		Name:  name.Value,
+		Value: v,
+		Span:  Span{Start: p.tokenPos(name), End: p.end},
+	}, nil
+}
+
+// parseAttribute parses a flag or a property of node.
+func (p *Parser) parseAttribute(node *ComponentNode) error {
+	token := p.lexer.Peek()
+	if token.Type == lexer.Bool {
+		p.next()
+		node.addAttribute(&Attribute{
+			Name:  token.Value,
+			Value: true,
+			Span:  Span{Start: p.tokenPos(token), End: p.end},
+		})
+		return nil
+	}
+	attr, err := p.parseProperty()
+	if err != nil {
+		return err
 	}
-	return name, v, nil
+	node.addAttribute(attr)
+	return nil
 }
 
 func (p *Parser) parseComponentNode() (*ComponentNode, error) {
+	token := p.next()
 	node := &ComponentNode{
-		Name:       p.lexer.Next().Value,
+		Name:       token.Value,
 		attributes: make([]ast.Attribute, 0),
 	}
+	node.span.Start = p.tokenPos(token)
+	defer func() {
+		node.span.End = p.end
+	}()
 	baseIndent := p.indent
 
+	// properties on the component line. Ex.: \Each Items: {{ data.team }}
+	for token := p.lexer.Peek(); token.Type == lexer.Bool || token.Type == lexer.Property; token = p.lexer.Peek() {
+		if err := p.parseAttribute(node); err != nil {
+			if err := p.recoverFrom(err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	for token := p.lexer.Peek(); token.Type != lexer.EOF; token = p.lexer.Peek() {
 		if token.Type == lexer.Indent {
 			p.indent++
-			p.lexer.Next()
+			p.next()
 			continue
 		}
 		if token.Type == lexer.LineBreak {
 			p.indent = 0
-			p.lexer.Next()
+			p.next()
+			continue
+		}
+		if token.Type == lexer.Illegal {
+			if err := p.invalidIndent(token); err != nil {
+				return nil, err
+			}
 			continue
 		}
 		if p.indent <= baseIndent {
@@ -210,31 +529,26 @@ func (p *Parser) parseComponentNode() (*ComponentNode, error) {
 		}
 		switch token.Type {
 		case lexer.Text:
-			p.lexer.Next()
-			node.children = append(node.children, &TextNode{Value: token.Value})
+			node.children = append(node.children, p.parseTextNode())
 		case lexer.Component:
 			child, err := p.parseComponentNode()
 			if err != nil {
-				return nil, err
+				if err := p.recoverFrom(err); err != nil {
+					return nil, err
+				}
+				continue
 			}
 			node.children = append(node.children, child)
-		case lexer.Bool:
-			p.lexer.Next()
-			node.attributes = append(node.attributes, ast.Attribute{
-				Name:  []byte(token.Value),
-				Value: true,
-			})
-		case lexer.Property:
-			k, v, err := p.parseProperty()
-			if err != nil {
-				return nil, err
+		case lexer.Bool, lexer.Property:
+			if err := p.parseAttribute(node); err != nil {
+				if err := p.recoverFrom(err); err != nil {
+					return nil, err
+				}
 			}
-			node.attributes = append(node.attributes, ast.Attribute{
-				Name:  []byte(k),
-				Value: v,
-			})
 		default:
-			panic(fmt.Sprintf("unexpected %v: %v", token.Type, token.Value))
+			if err := p.recoverFrom(p.unexpected(token)); err != nil {
+				return nil, err
+			}
 		}
 	}
 	return node, nil
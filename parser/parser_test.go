@@ -3,6 +3,11 @@ package parser
 
 import (
 	"github.com/google/go-cmp/cmp"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 	"strings"
 	"testing"
 )
@@ -490,6 +495,192 @@ func TestParser_ParseWithComplexProps(t *testing.T) {
 //	}
 //}
 
+func TestParserWithLiterals(t *testing.T) {
+	input := `\Gallery
+    Count: 3
+    Ratio: 0.75
+    Big: 1e3
+    Visible: false
+    Image: null
+    !Rounded`
+
+	nodes, err := NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	gallery := nodes[0].(*ComponentNode)
+	expected := map[string]any{
+		"Count":   int64(3),
+		"Ratio":   0.75,
+		"Big":     1000.0,
+		"Visible": false,
+		"Image":   nil,
+		"Rounded": true,
+	}
+	actual := map[string]any{}
+	for _, attr := range gallery.Attributes() {
+		actual[string(attr.Name)] = attr.Value
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("Mismatch (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestParserWithListsAndObjects(t *testing.T) {
+	input := `\PricingTable
+    Features:
+        - "Fast"
+        - 3
+    Author:
+        Name: "Jane"
+        Links:
+            - Url: "https://example.com"
+                Title: "Site"
+    Plans:
+        - \Plan
+            Name: "Free"
+        - \Plan
+            Name: "Pro"
+    Buttons:
+        \Button
+        \Button`
+
+	nodes, err := NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	table := nodes[0].(*ComponentNode)
+	props := table.Properties()
+	if len(props) != 4 {
+		t.Fatalf("expected 4 properties, got %d", len(props))
+	}
+
+	features, ok := props[0].Value.(List)
+	if !ok {
+		t.Fatalf("expected Features to be a List, got %T", props[0].Value)
+	}
+	if diff := cmp.Diff(List{"Fast", int64(3)}, features); diff != "" {
+		t.Errorf("Features mismatch (-expected +actual):\n%s", diff)
+	}
+
+	author, ok := props[1].Value.(Object)
+	if !ok {
+		t.Fatalf("expected Author to be an Object, got %T", props[1].Value)
+	}
+	if name, ok := author.Get("Name"); !ok || name.Value != "Jane" {
+		t.Errorf("expected Author.Name to be Jane, got %v", name)
+	}
+	links, _ := author.Get("Links")
+	link := links.Value.(List)[0].(Object)
+	if len(link) != 2 || link[0].Value != "https://example.com" || link[1].Value != "Site" {
+		t.Errorf("unexpected link %v", link)
+	}
+
+	plans := props[2].Value.(List)
+	if len(plans) != 2 || plans[1].(*ComponentNode).Properties()[0].Value != "Pro" {
+		t.Errorf("unexpected plans %v", plans)
+	}
+
+	buttons, ok := props[3].Value.(List)
+	if !ok || len(buttons) != 2 {
+		t.Errorf("expected Buttons to be a List of 2 components, got %v", props[3].Value)
+	}
+}
+
+func TestParserWithMixedValue(t *testing.T) {
+	input := `\Card
+    Items:
+        - "a"
+        Name: "b"`
+
+	_, err := NewMargoParser(input).Parse()
+	diags, ok := err.(Diagnostics)
+	if !ok || len(diags) != 1 || diags[0].Code != CodeMixedValue {
+		t.Fatalf("expected a %s diagnostic, got %v", CodeMixedValue, err)
+	}
+}
+
+func TestParserWithComments(t *testing.T) {
+	input := `// drafts below are disabled
+\HeroV2
+    Title: "Hello"
+    // !Visible
+    /*
+    \ButtonPrimary
+        Href: "https://example.com"
+    */
+\Footer`
+
+	nodes, err := NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(nodes))
+	}
+	hero := nodes[0].(*ComponentNode)
+	if len(hero.Attributes()) != 1 || len(hero.Children()) != 0 {
+		t.Errorf("expected commented out content to be skipped, got %d attributes and %d children",
+			len(hero.Attributes()), len(hero.Children()))
+	}
+	if nodes[1].(*ComponentNode).Name != "Footer" {
+		t.Errorf("expected second node to be Footer, got %s", nodes[1].(*ComponentNode).Name)
+	}
+}
+
+func TestParserWithExpressions(t *testing.T) {
+	nodes, err := NewMargoParser("\\Hero\n    Title: {{ page.title }}").Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	expr, ok := nodes[0].(*ComponentNode).Properties()[0].Value.(*Expr)
+	if !ok {
+		t.Fatalf("expected an *Expr, got %T", nodes[0].(*ComponentNode).Properties()[0].Value)
+	}
+	if diff := cmp.Diff([]string{"page", "title"}, expr.Path); diff != "" {
+		t.Errorf("Mismatch (-expected +actual):\n%s", diff)
+	}
+
+	_, err = NewMargoParser("\\Hero\n    Title: {{ page title }}").Parse()
+	if diags, ok := err.(Diagnostics); !ok || diags[0].Code != CodeInvalidExpr {
+		t.Errorf("expected a %s diagnostic, got %v", CodeInvalidExpr, err)
+	}
+
+	s, err := Interpolate("{{ site.name }}: {{ .Go }} {{ page.title}}", func(e *Expr) (any, error) {
+		return strings.Join(e.Path, "/"), nil
+	})
+	if err != nil {
+		t.Fatalf("Interpolate() failed: %v", err)
+	}
+	if expected := "site/name: {{ .Go }} page/title"; s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+}
+
+func TestParserWithInlineProperties(t *testing.T) {
+	input := `\Each Items: {{ data.team }} As: "member"
+    \Card !Wide
+        Title: {{ member.name }}`
+
+	nodes, err := NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	each := nodes[0].(*ComponentNode)
+	if props := each.Properties(); len(props) != 2 || props[0].Name != "Items" || props[1].Value != "member" {
+		t.Errorf("unexpected properties of Each %v", props)
+	}
+	card := each.Children()[0].(*ComponentNode)
+	if props := card.Properties(); len(props) != 2 || props[0].Name != "Wide" || props[1].Name != "Title" {
+		t.Errorf("unexpected properties of Card %v", props)
+	}
+
+	_, err = NewMargoParser("\\Hero\n    Title: {{ page.title }} | Docs").Parse()
+	if diags, ok := err.(Diagnostics); !ok || diags[0].Code != CodeInvalidExpr {
+		t.Errorf("expected a %s diagnostic, got %v", CodeInvalidExpr, err)
+	}
+}
+
 func TestParserWithEmptyInput(t *testing.T) {
 	input := ""
 	nodes, err := NewMargoParser(input).Parse()
@@ -501,3 +692,202 @@ func TestParserWithEmptyInput(t *testing.T) {
 		t.Fatalf("Expected no nodes for empty input, got %d", len(nodes))
 	}
 }
+
+func TestParserDiagnostics(t *testing.T) {
+	input := `\HeroV2
+    Title: Hello
+    :
+  \ButtonPrimary
+        Href "https://example.com"`
+
+	_, err := NewMargoParser(input, WithFile("page.md"), WithLineOffset(10)).Parse()
+	diags, ok := err.(Diagnostics)
+	if !ok {
+		t.Fatalf("expected Diagnostics, got %T", err)
+	}
+	if len(diags) != 1 {
+		t.Fatalf("expected parsing to stop at the first error, got %d diagnostics", len(diags))
+	}
+
+	nodes, err := NewMargoParser(input, WithFile("page.md"), WithLineOffset(10), WithRecovery()).Parse()
+	diags, ok = err.(Diagnostics)
+	if !ok {
+		t.Fatalf("expected Diagnostics, got %T", err)
+	}
+	expected := []*Diagnostic{
+		{File: "page.md", Line: 12, Column: 12, Code: CodeExpectedValue},
+		{File: "page.md", Line: 13, Column: 5, Code: CodeUnexpectedToken},
+		{File: "page.md", Line: 14, Column: 1, Code: CodeInvalidIndent},
+	}
+	if len(diags) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got:\n%s", len(expected), diags.Error())
+	}
+	for i, exp := range expected {
+		diag := diags[i]
+		if diag.File != exp.File || diag.Line != exp.Line || diag.Column != exp.Column || diag.Code != exp.Code {
+			t.Errorf("expected %s:%d:%d %s, got %s", exp.File, exp.Line, exp.Column, exp.Code, diag.Error())
+		}
+	}
+	if len(nodes) != 1 || len(nodes[0].Children()) != 1 {
+		t.Fatalf("expected the valid part of the input to be parsed, got %v", nodes)
+	}
+
+	expectedPretty := "page.md:12:12: error[expected-value]: expected \" or newline got Hello\n" +
+		"   |\n" +
+		"12 |     Title: Hello\n" +
+		"   |            ^"
+	if diff := cmp.Diff(expectedPretty, diags[0].Pretty()); diff != "" {
+		t.Errorf("Mismatch (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestParserSpans(t *testing.T) {
+	input := `\HeroV2
+    Title: "Hello"
+    !Visible
+    Text`
+
+	nodes, err := NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	hero := nodes[0].(*ComponentNode)
+	expected := Span{Start: Position{0, 1, 1}, End: Position{48, 4, 9}}
+	if diff := cmp.Diff(expected, hero.Span()); diff != "" {
+		t.Errorf("Component span mismatch (-expected +actual):\n%s", diff)
+	}
+
+	props := hero.Properties()
+	if len(props) != 2 {
+		t.Fatalf("expected 2 properties, got %d", len(props))
+	}
+	expected = Span{Start: Position{12, 2, 5}, End: Position{26, 2, 19}}
+	if diff := cmp.Diff(expected, props[0].Span); diff != "" {
+		t.Errorf("Title span mismatch (-expected +actual):\n%s", diff)
+	}
+	expected = Span{Start: Position{31, 3, 5}, End: Position{39, 3, 13}}
+	if diff := cmp.Diff(expected, props[1].Span); diff != "" {
+		t.Errorf("Visible span mismatch (-expected +actual):\n%s", diff)
+	}
+
+	text := hero.Children()[0].(*TextNode)
+	expected = Span{Start: Position{44, 4, 5}, End: Position{48, 4, 9}}
+	if diff := cmp.Diff(expected, text.Span()); diff != "" {
+		t.Errorf("Text span mismatch (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestBlockParserSpans(t *testing.T) {
+	source := []byte("# Title\n\n```margo\n\\HeroV2\n    Title: \"Hello\"\n```\n")
+	md := goldmark.New(goldmark.WithParserOptions(
+		parser.WithBlockParsers(util.Prioritized(BlockParser(), 0)),
+	))
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var block *Document
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if d, ok := n.(*Document); ok && entering {
+			block = d
+		}
+		return ast.WalkContinue, nil
+	})
+	if block == nil {
+		t.Fatal("margo block not found")
+	}
+	if block.Err != nil {
+		t.Fatalf("unexpected error: %v", block.Err)
+	}
+
+	hero := block.Children[0].(*ComponentNode)
+	expected := Span{Start: Position{18, 4, 1}, End: Position{44, 5, 19}}
+	if diff := cmp.Diff(expected, hero.Span()); diff != "" {
+		t.Errorf("Component span mismatch (-expected +actual):\n%s", diff)
+	}
+	title := hero.Properties()[0]
+	if got := string(source[title.Span.Start.Offset:title.Span.End.Offset]); got != `Title: "Hello"` {
+		t.Errorf("expected span to cover the property, got %q", got)
+	}
+}
+
+func TestParserWithIndentWidth(t *testing.T) {
+	input := `\HeroV2
+  Title: "Hello"
+  \ButtonPrimary
+    Href: "/demo"
+    See Demo`
+
+	nodes, err := NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	hero := nodes[0].(*ComponentNode)
+	if len(hero.Properties()) != 1 || len(hero.Children()) != 1 {
+		t.Fatalf("expected 1 property and 1 child, got %d and %d", len(hero.Properties()), len(hero.Children()))
+	}
+	button := hero.Children()[0].(*ComponentNode)
+	if len(button.Properties()) != 1 || len(button.Children()) != 1 {
+		t.Errorf("expected 1 property and 1 child, got %d and %d", len(button.Properties()), len(button.Children()))
+	}
+
+	_, err = NewMargoParser(input, WithIndentWidth(4)).Parse()
+	diags, ok := err.(Diagnostics)
+	if !ok || diags[0].Code != CodeInvalidIndent || diags[0].Line != 2 {
+		t.Fatalf("expected an invalid indent on line 2, got %v", err)
+	}
+	if expected := "invalid indent of 2 spaces, expected a multiple of 4 spaces"; diags[0].Message != expected {
+		t.Errorf("expected %q, got %q", expected, diags[0].Message)
+	}
+}
+
+func TestParserWithStrings(t *testing.T) {
+	input := `\Code
+    Address: "1 Main St\nSpringfield"
+    Source: """
+        if x {
+            return "y"
+        }
+        """
+    Summary: >
+        A long sentence
+        on two lines.`
+
+	nodes, err := NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	props := nodes[0].(*ComponentNode).Properties()
+	expected := []any{
+		"1 Main St\nSpringfield",
+		RawString("if x {\n    return \"y\"\n}"),
+		"A long sentence on two lines.",
+	}
+	if len(props) != len(expected) {
+		t.Fatalf("expected %d properties, got %d", len(expected), len(props))
+	}
+	for i, exp := range expected {
+		if props[i].Value != exp {
+			t.Errorf("expected %s to be %q, got %q", props[i].Name, exp, props[i].Value)
+		}
+	}
+	if end := props[1].Span.End; end.Line != 7 || end.Column != 12 {
+		t.Errorf("expected the raw string to end at 7:12, got %v", end)
+	}
+
+	tests := map[string]struct {
+		input string
+		code  string
+		line  int
+	}{
+		"invalid escape":    {"\\Card\n    Title: \"a\\qb\"", CodeInvalidEscape, 2},
+		"raw string indent": {"\\Card\n    Source: \"\"\"\n      a\n        \"\"\"", CodeMalformedString, 2},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewMargoParser(tt.input).Parse()
+			diags, ok := err.(Diagnostics)
+			if !ok || diags[0].Code != tt.code || diags[0].Line != tt.line {
+				t.Errorf("expected %s on line %d, got %v", tt.code, tt.line, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yuin/goldmark/text"
+)
+
+// Position is a location in the parsed source.
+// Offset is in bytes, Line and Column are 1-based, Column counting bytes.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span is the range of source a node was parsed from. End is exclusive.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// sourceMap translates positions in the content of a margo block to the
+// markdown file the block was read from.
+type sourceMap struct {
+	source    []byte
+	segments  []text.Segment
+	starts    []int // offset of every line in the block content
+	firstLine int   // line of the first segment in the file
+}
+
+func newSourceMap(source []byte, lines *text.Segments) *sourceMap {
+	m := &sourceMap{
+		source:   source,
+		segments: lines.Sliced(0, lines.Len()),
+	}
+	offset := 0
+	for _, seg := range m.segments {
+		m.starts = append(m.starts, offset)
+		offset += len(seg.Value(source))
+	}
+	if len(m.segments) > 0 {
+		m.firstLine = bytes.Count(source[:m.segments[0].Start], []byte("\n")) + 1
+	}
+	return m
+}
+
+// segment returns the index of the segment holding the given line of the block.
+func (m *sourceMap) segment(line int) int {
+	return max(0, min(line-1, len(m.segments)-1))
+}
+
+// position maps a position in the block content to the file.
+func (m *sourceMap) position(offset, line int) Position {
+	if len(m.segments) == 0 {
+		return Position{Offset: offset, Line: line, Column: 1}
+	}
+	i := m.segment(line)
+	seg := m.segments[i]
+	fileOffset := seg.Start + max(0, offset-m.starts[i]-seg.Padding)
+	lineStart := bytes.LastIndexByte(m.source[:seg.Start], '\n') + 1
+	return Position{
+		Offset: fileOffset,
+		Line:   m.firstLine + i,
+		Column: fileOffset - lineStart + 1,
+	}
+}
+
+// lineText returns the line of the file holding the given line of the block.
+func (m *sourceMap) lineText(line int) string {
+	if len(m.segments) == 0 {
+		return ""
+	}
+	seg := m.segments[m.segment(line)]
+	lineStart := bytes.LastIndexByte(m.source[:seg.Start], '\n') + 1
+	lineEnd := bytes.IndexByte(m.source[lineStart:], '\n')
+	if lineEnd == -1 {
+		return string(m.source[lineStart:])
+	}
+	return string(bytes.TrimSuffix(m.source[lineStart:lineStart+lineEnd], []byte("\r")))
+}
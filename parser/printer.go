@@ -0,0 +1,328 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/iota-uz/margo/lexer"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// indentUnit is the indentation printed for every level of nesting.
+const indentUnit = "    "
+
+// Print turns nodes back into margo source in canonical form: one property per line
+// sorted by name, properties before children and 4 spaces of indentation.
+// A blank line between two nodes is kept, other blank lines are dropped.
+// Values the syntax cannot express, such as an empty list, are reported as an error.
+func Print(nodes []Node) (string, error) {
+	p := &printer{}
+	if err := p.nodes(nodes, 0); err != nil {
+		return "", err
+	}
+	return p.b.String(), nil
+}
+
+type printer struct {
+	b strings.Builder
+}
+
+func (p *printer) line(indent int, s string) {
+	p.b.WriteString(strings.Repeat(indentUnit, indent))
+	p.b.WriteString(s)
+	p.b.WriteByte('\n')
+}
+
+func (p *printer) nodes(nodes []Node, indent int) error {
+	for i, node := range nodes {
+		if i > 0 && node.Span().Start.Line-nodes[i-1].Span().End.Line > 1 {
+			p.b.WriteByte('\n')
+		}
+		if err := p.node(node, indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *printer) node(node Node, indent int) error {
+	switch n := node.(type) {
+	case *ComponentNode:
+		return p.component(n, indent)
+	case *TextNode:
+		if !isTextLine(n.Value) {
+			return fmt.Errorf("%s: cannot print text %q on its own line", n.span.Start, n.Value)
+		}
+		p.line(indent, n.Value)
+		return nil
+	default:
+		return fmt.Errorf("cannot print node of type %T", node)
+	}
+}
+
+func (p *printer) component(node *ComponentNode, indent int) error {
+	p.line(indent, "\\"+node.Name)
+	if err := p.attributes(node.Properties(), indent+1, true); err != nil {
+		return err
+	}
+	return p.nodes(node.Children(), indent+1)
+}
+
+// sorted returns attrs sorted by name, keeping the order of duplicates.
+func sorted(attrs []*Attribute) []*Attribute {
+	attrs = append([]*Attribute(nil), attrs...)
+	sort.SliceStable(attrs, func(i, j int) bool {
+		return attrs[i].Name < attrs[j].Name
+	})
+	return attrs
+}
+
+// attributes prints attrs sorted by name. Flags are only allowed on components,
+// object properties being printed as `Name: true`.
+func (p *printer) attributes(attrs []*Attribute, indent int, flags bool) error {
+	for _, attr := range sorted(attrs) {
+		if flags && attr.Value == true {
+			p.line(indent, "!"+attr.Name)
+			continue
+		}
+		if err := p.property(attr, indent, indent+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// property prints `Name: value`, a value spanning several lines being indented
+// at valueIndent.
+func (p *printer) property(attr *Attribute, indent, valueIndent int) error {
+	return p.value(strings.Repeat(indentUnit, indent)+attr.Name+":", attr.Value, valueIndent)
+}
+
+// value prints v after prefix, either on the same line or as an indented block.
+// Strings spanning several lines are printed as lines of text when possible,
+// otherwise as a raw string or a quoted string.
+func (p *printer) value(prefix string, v any, indent int) error {
+	if s, ok := scalar(v); ok {
+		p.b.WriteString(prefix + " " + s + "\n")
+		return nil
+	}
+	if s, ok := v.(RawString); ok {
+		return p.raw(prefix, string(s), indent)
+	}
+	if s, ok := v.(string); ok && !isTextBlock(s) {
+		if !isRaw(s) {
+			p.b.WriteString(prefix + " " + quote(s) + "\n")
+			return nil
+		}
+		return p.raw(prefix, s, indent)
+	}
+	p.b.WriteString(prefix + "\n")
+	return p.block(v, indent)
+}
+
+// raw prints s after prefix as a raw string, on the same line when it fits.
+func (p *printer) raw(prefix, s string, indent int) error {
+	if !strings.Contains(s, "\n") && !strings.Contains(s, `"""`) && !strings.HasSuffix(s, `"`) {
+		p.b.WriteString(prefix + ` """` + s + `"""` + "\n")
+		return nil
+	}
+	if !isRaw(s) {
+		return fmt.Errorf("cannot print raw string %q", s)
+	}
+	p.b.WriteString(prefix + ` """` + "\n")
+	for _, line := range strings.Split(s, "\n") {
+		if line == "" {
+			p.b.WriteByte('\n')
+			continue
+		}
+		p.line(indent, line)
+	}
+	p.line(indent, `"""`)
+	return nil
+}
+
+// block prints a value that does not fit on the line of its property.
+func (p *printer) block(v any, indent int) error {
+	switch v := v.(type) {
+	case string:
+		for _, line := range strings.Split(v, "\n") {
+			p.line(indent, line)
+		}
+		return nil
+	case *ComponentNode:
+		return p.component(v, indent)
+	case List:
+		return p.list(v, indent)
+	case Object:
+		if len(v) == 0 {
+			return fmt.Errorf("cannot print empty object")
+		}
+		return p.attributes(v, indent, false)
+	default:
+		return fmt.Errorf("cannot print value of type %T", v)
+	}
+}
+
+func (p *printer) list(list List, indent int) error {
+	if len(list) == 0 {
+		return fmt.Errorf("cannot print empty list")
+	}
+	components := true
+	for _, item := range list {
+		if _, ok := item.(*ComponentNode); !ok {
+			components = false
+		}
+	}
+	// several components form a list without dashes
+	if components && len(list) > 1 {
+		for _, item := range list {
+			if err := p.component(item.(*ComponentNode), indent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	dash := strings.Repeat(indentUnit, indent) + "-"
+	for _, item := range list {
+		if err := p.listItem(dash, item, indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listItem prints an item of a list. The first property of an object goes on the
+// line of the dash unless its value is a block, which would swallow the properties
+// following it.
+//
+//   - Name: "Free"
+//     Price: 0
+func (p *printer) listItem(dash string, item any, indent int) error {
+	switch item := item.(type) {
+	case *ComponentNode:
+		p.b.WriteString(dash + " \\" + item.Name + "\n")
+		if err := p.attributes(item.Properties(), indent+1, true); err != nil {
+			return err
+		}
+		return p.nodes(item.Children(), indent+1)
+	case Object:
+		attrs := sorted(item)
+		if len(attrs) == 0 {
+			return fmt.Errorf("cannot print empty object")
+		}
+		if _, ok := scalar(attrs[0].Value); !ok {
+			p.b.WriteString(dash + "\n")
+			return p.attributes(attrs, indent+1, false)
+		}
+		if err := p.value(dash+" "+attrs[0].Name+":", attrs[0].Value, indent+2); err != nil {
+			return err
+		}
+		for _, attr := range attrs[1:] {
+			if err := p.property(attr, indent+1, indent+2); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return p.value(dash, item, indent+1)
+	}
+}
+
+// scalar formats a value fitting on the line of its property.
+func scalar(v any) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "null", true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", false
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			// keep the value a float when parsed back
+			s += ".0"
+		}
+		return s, true
+	case string:
+		if strings.Contains(v, "\n") {
+			return "", false
+		}
+		return quote(v), true
+	case *Expr:
+		return v.String(), true
+	default:
+		return "", false
+	}
+}
+
+// isTextLine reports whether s is read back as a single line of text,
+// as opposed to a property, a component, a comment or an indent.
+// A line opening a comment is rejected even when the lexer keeps it, as an
+// unclosed `/*` may be closed by the lines printed after it.
+func isTextLine(s string) bool {
+	if strings.HasPrefix(s, "//") || strings.HasPrefix(s, "/*") {
+		return false
+	}
+	l := lexer.New("\n" + s)
+	if l.Next().Type != lexer.LineBreak {
+		return false
+	}
+	token := l.Next()
+	return token.Type == lexer.Text && token.Value == s && l.Next().Type == lexer.EOF
+}
+
+// isTextBlock reports whether every line of s is read back as a line of text.
+func isTextBlock(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if !isTextLine(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// isRaw reports whether s is read back as is from a raw string spanning several lines.
+// Blank lines are read back empty.
+func isRaw(s string) bool {
+	if strings.Contains(s, `"""`) {
+		return false
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" && strings.TrimSpace(line) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// quote quotes s, escaping the characters that cannot appear as is in a string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < ' ' {
+				fmt.Fprintf(&b, `\u%04x`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,234 @@
+package parser
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"reflect"
+	"testing"
+)
+
+func TestPrint(t *testing.T) {
+	input := `\Hero   !Wide
+    Title: "Hello"
+    Count: 3
+    See the demo
+
+
+    \Button
+            Href: "/demo"
+    \Each Items: {{ data.team }} As: "member"
+        Text:
+            He said "hi"
+            twice`
+
+	expected := `\Hero
+    Count: 3
+    Title: "Hello"
+    !Wide
+    See the demo
+
+    \Button
+        Href: "/demo"
+    \Each
+        As: "member"
+        Items: {{ data.team }}
+        Text:
+            He said "hi"
+            twice
+`
+	nodes, err := NewMargoParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	out, err := Print(nodes)
+	if err != nil {
+		t.Fatalf("Print() failed: %v", err)
+	}
+	if diff := cmp.Diff(expected, out); diff != "" {
+		t.Errorf("Print() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestPrintRoundTrip checks that printed nodes are parsed back into the same nodes
+// and that printing is idempotent.
+func TestPrintRoundTrip(t *testing.T) {
+	inputs := map[string]string{
+		"basic": `\HeroV2
+    Title: "Congratulations!"
+    !Visible
+    \ButtonPrimary
+        Href: "https://example.com"
+        See Demo`,
+		"nested components": `\Features
+    Title: "### A robust set of features"
+    Description: "Based on community suggestions"
+    \Card
+        Title: "Blazing Fast Performance"
+        Icon:
+            \IconLightning
+                Size: "24"
+                Variant: "DuoTone"`,
+		"multiline text": `\Description
+    Text:
+        This is a multiline description
+        that spans multiple lines.
+    MoreText: "More single-line text."`,
+		"navigation": `\Header
+    \Link
+        Href: "/features"
+        Features
+    \Link
+        Href: "/services"
+        Services
+\Slot`,
+		"literals": `\Gallery
+    Count: 3
+    Ratio: 0.75
+    Big: 1e3
+    Whole: 2.0
+    Visible: false
+    Image: null
+    !Rounded`,
+		"lists and objects": `\PricingTable
+    Features:
+        - "Fast"
+        - 3
+        -
+            Multi
+            line
+    Author:
+        Name: "Jane"
+        Links:
+            - Url: "https://example.com"
+                Title: "Site"
+            -
+                Tags:
+                    - "a"
+                Name: "Blog"
+            -
+                Links:
+                    - "b"
+                Tags:
+                    - "c"
+    Plans:
+        - \Plan
+            Name: "Free"
+            !Popular
+    Buttons:
+        \Button
+        \Button`,
+		"strings": `\Code
+    Address: "1 Main St\nSpringfield"
+    Quoted: "He said \"hi\" \\ \u00e9\t"
+    Source: """
+        if x {
+            return "y"
+        }
+
+        Title: text
+        Hello {{ name }}
+        """
+    Inline: """{{ kept }}"""
+    Mixed: "  leading\n   \nblank"
+    Summary: >
+        A long sentence
+        on two lines.
+
+        Next paragraph.`,
+		"expressions": `\Each Items: {{ data.team }} As: "member"
+    \Card !Wide
+        Title: {{ member.name }}
+        Subtitle: "{{ member.role }} at {{ site.name }}"`,
+	}
+
+	ignoreSpans := cmp.Options{
+		cmp.Exporter(func(reflect.Type) bool { return true }),
+		cmpopts.IgnoreTypes(Span{}),
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			nodes, err := NewMargoParser(input).Parse()
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+			out, err := Print(nodes)
+			if err != nil {
+				t.Fatalf("Print() failed: %v", err)
+			}
+			reparsed, err := NewMargoParser(out).Parse()
+			if err != nil {
+				t.Fatalf("Parse() of printed nodes failed: %v\n%s", err, out)
+			}
+			// properties are sorted by the printer
+			sortProperties(nodes)
+			sortProperties(reparsed)
+			if diff := cmp.Diff(nodes, reparsed, ignoreSpans); diff != "" {
+				t.Errorf("round trip mismatch (-want +got):\n%s\n%s", diff, out)
+			}
+			again, err := Print(reparsed)
+			if err != nil {
+				t.Fatalf("Print() failed: %v", err)
+			}
+			if again != out {
+				t.Errorf("Print() is not idempotent:\n%s\n---\n%s", out, again)
+			}
+		})
+	}
+}
+
+func TestPrintErrors(t *testing.T) {
+	tests := map[string]any{
+		"empty list":   List{},
+		"empty object": Object{},
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			node := &ComponentNode{Name: "Card"}
+			node.addAttribute(&Attribute{Name: "Value", Value: value})
+			if _, err := Print([]Node{node}); err == nil {
+				t.Errorf("expected an error printing %#v", value)
+			}
+		})
+	}
+}
+
+func TestPrintCommentText(t *testing.T) {
+	// text following a component on its line opens a comment once on its own line
+	nodes, err := NewMargoParser("\\Hero/*{{ page.a }}\n-1.5e3*///\r3/*").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := Print(nodes); err == nil {
+		t.Errorf("expected an error, got %q", out)
+	}
+}
+
+func sortProperties(nodes []Node) {
+	for _, node := range nodes {
+		if c, ok := node.(*ComponentNode); ok {
+			props := sorted(c.properties)
+			c.properties, c.attributes = nil, nil
+			for _, attr := range props {
+				c.addAttribute(attr)
+				sortValue(attr.Value)
+			}
+			sortProperties(c.children)
+		}
+	}
+}
+
+func sortValue(v any) {
+	switch v := v.(type) {
+	case *ComponentNode:
+		sortProperties([]Node{v})
+	case List:
+		for _, item := range v {
+			sortValue(item)
+		}
+	case Object:
+		copy(v, sorted(v))
+		for _, attr := range v {
+			sortValue(attr.Value)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package registry
 
 import (
+	"context"
 	"errors"
 	"github.com/a-h/templ"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -95,23 +97,41 @@ func (r *layout) Namespace(name string) (Layout, error) {
 	return v.subLayout, nil
 }
 
+var (
+	contextType   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	componentType = reflect.TypeOf((*templ.Component)(nil)).Elem()
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+)
+
 // Register registers a component with the given name.
-// The component signature must be func(struct) templ.Component.
+// The component is a function taking an optional context.Context followed by
+// an optional props struct, and returning a templ.Component with an optional error:
+//
+//	func(props Props) templ.Component
+//	func(props Props) (templ.Component, error)
+//	func(ctx context.Context, props Props) (templ.Component, error)
 func (r *layout) Register(name string, component any) Layout {
 	n := strings.ToLower(name)
 	t := reflect.TypeOf(component)
-	if t.Kind() != reflect.Func {
+	if t == nil || t.Kind() != reflect.Func {
 		panic("component must be a function")
 	}
-	if t.NumIn() == 1 && t.In(0).Kind() != reflect.Struct {
-		panic("component must take in a single struct")
+	in := 0
+	if t.NumIn() > 0 && t.In(0) == contextType {
+		in++
+	}
+	if t.NumIn() > in+1 || t.NumIn() == in+1 && t.In(in).Kind() != reflect.Struct {
+		panic("component must take in an optional context.Context and a single struct")
 	}
-	if t.NumOut() != 1 {
-		panic("component must return a single value")
+	if t.NumOut() == 0 || t.NumOut() > 2 {
+		panic("component must return templ.Component and an optional error")
 	}
-	if t.Out(0) != reflect.TypeOf((*templ.Component)(nil)).Elem() {
+	if t.Out(0) != componentType {
 		panic("component must return templ.Component")
 	}
+	if t.NumOut() == 2 && t.Out(1) != errorType {
+		panic("component must return an error as its second value")
+	}
 	subLayout := NewLayout(name)
 	r.components[n] = &item{
 		value:     component,
@@ -142,10 +162,12 @@ func (r *layout) Get(name string) (any, bool) {
 	return v.value, true
 }
 
+// List returns the names of the components as registered, sorted.
 func (r *layout) List() []string {
 	var res []string
-	for k := range r.components {
-		res = append(res, k)
+	for _, v := range r.components {
+		res = append(res, v.subLayout.Name())
 	}
+	sort.Strings(res)
 	return res
 }
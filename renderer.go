@@ -22,10 +22,16 @@ type Renderer interface {
 }
 
 // ContextKey is used for context value storage
-type ContextKey struct{}
+type ContextKey struct {
+	name string
+}
+
+// slotComponent renders the page in a layout file.
+const slotComponent = "Slot"
 
-var slotKey = ContextKey{}
-var layoutKey = ContextKey{}
+var slotKey = ContextKey{"slot"}
+var namedSlotsKey = ContextKey{"namedSlots"}
+var layoutKey = ContextKey{"layout"}
 
 // WithLayout adds a layout to context
 func WithLayout(ctx context.Context, layout string) context.Context {
@@ -49,6 +55,25 @@ func GetSlot(ctx context.Context) (templ.Component, bool) {
 	return c, ok
 }
 
+// WithNamedSlot adds the component filling the slot of a layout file named name,
+// rendered by \Slot Name: "name".
+func WithNamedSlot(ctx context.Context, name string, component templ.Component) context.Context {
+	slots, _ := ctx.Value(namedSlotsKey).(map[string]templ.Component)
+	scoped := make(map[string]templ.Component, len(slots)+1)
+	for k, v := range slots {
+		scoped[k] = v
+	}
+	scoped[name] = component
+	return context.WithValue(ctx, namedSlotsKey, scoped)
+}
+
+// GetNamedSlot retrieves the component filling the slot named name from context
+func GetNamedSlot(ctx context.Context, name string) (templ.Component, bool) {
+	slots, _ := ctx.Value(namedSlotsKey).(map[string]templ.Component)
+	c, ok := slots[name]
+	return c, ok
+}
+
 // MarkdownRenderer implements custom markdown rendering
 type MarkdownRenderer struct {
 	layout               registry.Layout
@@ -170,6 +195,8 @@ func (nr *NodeRenderer) RenderNode(ctx context.Context, w io.Writer, source []by
 		switch n.Kind() {
 		case parser.KindMargoNode:
 			return nr.renderMargoNode(ctx, writer, source, n, entering)
+		case parser.KindDirective, parser.KindDirectiveBlock:
+			return nr.renderDirective(ctx, writer, source, n, entering)
 		case ast.KindHeading:
 			return nr.renderHeading(ctx, writer, source, n, entering)
 		case ast.KindParagraph:
@@ -202,11 +229,56 @@ func (nr *NodeRenderer) renderMargoNode(ctx context.Context, w util.BufWriter, s
 	}
 
 	n := node.(*parser.Document)
-	for _, child := range n.Children {
-		if err := nr.renderComponent(ctx, w, child, nil); err != nil {
-			return ast.WalkStop, err
+	ctx = withBlock(ctx, n)
+	if n.Err != nil {
+		return ast.WalkStop, newRenderError(ctx, n.Err)
+	}
+	err := nr.renderNodes(ctx, w, n.Children, func(ctx context.Context, w io.Writer, node parser.Node) error {
+		return nr.renderComponent(ctx, w, node, nil)
+	})
+	if err != nil {
+		return ast.WalkStop, newRenderError(ctx, err)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// renderDirective renders an inline or container directive with the component it names,
+// its content being the children of the component.
+func (nr *NodeRenderer) renderDirective(ctx context.Context, w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	var name string
+	switch n := node.(type) {
+	case *parser.Directive:
+		name = n.Name
+	case *parser.DirectiveBlock:
+		name = n.Name
+	}
+	ctx = withComponent(ctx, name)
+	component, err := nr.builder.GetComponent(name, nil)
+	if err != nil {
+		return ast.WalkStop, newRenderError(ctx, err)
+	}
+
+	children := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		for c := node.FirstChild(); c != nil; c = c.NextSibling() {
+			if err := nr.RenderNode(ctx, w, source, c); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+
+	cmp, err := nr.builder.Build(ctx, component, node.Attributes())
+	if err != nil {
+		return ast.WalkStop, newRenderError(ctx, fmt.Errorf("failed to build component %s: %w", name, err))
+	}
+
+	if err := cmp.Render(templ.WithChildren(ctx, children), w); err != nil {
+		return ast.WalkStop, newRenderError(ctx, err)
 	}
+
 	return ast.WalkSkipChildren, nil
 }
 
@@ -233,7 +305,7 @@ func (nr *NodeRenderer) renderImage(ctx context.Context, w util.BufWriter, sourc
 			Value: string(n.Title),
 		})
 	}
-	cmp, err := nr.builder.Build(component, attributes)
+	cmp, err := nr.builder.Build(ctx, component, attributes)
 	if err != nil {
 		return ast.WalkStop, err
 	}
@@ -265,7 +337,7 @@ func (nr *NodeRenderer) renderList(ctx context.Context, w util.BufWriter, source
 	})
 
 	ctx = templ.WithChildren(ctx, children)
-	cmp, err := nr.builder.Build(component, n.Attributes())
+	cmp, err := nr.builder.Build(ctx, component, n.Attributes())
 	if err != nil {
 		return ast.WalkStop, err
 	}
@@ -297,7 +369,7 @@ func (nr *NodeRenderer) renderListItem(ctx context.Context, w util.BufWriter, so
 	})
 
 	ctx = templ.WithChildren(ctx, children)
-	cmp, err := nr.builder.Build(component, n.Attributes())
+	cmp, err := nr.builder.Build(ctx, component, n.Attributes())
 	if err != nil {
 		return ast.WalkStop, err
 	}
@@ -329,7 +401,7 @@ func (nr *NodeRenderer) renderLink(ctx context.Context, w util.BufWriter, source
 	})
 
 	ctx = templ.WithChildren(ctx, children)
-	cmp, err := nr.builder.Build(component, append(n.Attributes(), ast.Attribute{
+	cmp, err := nr.builder.Build(ctx, component, append(n.Attributes(), ast.Attribute{
 		Name:  []byte("Href"),
 		Value: string(n.Destination),
 	}))
@@ -354,7 +426,7 @@ func (nr *NodeRenderer) renderHr(ctx context.Context, w util.BufWriter, source [
 		return ast.WalkContinue, nil
 	}
 
-	cmp, err := nr.builder.Build(component, nil)
+	cmp, err := nr.builder.Build(ctx, component, nil)
 	if err != nil {
 		return ast.WalkStop, err
 	}
@@ -386,7 +458,7 @@ func (nr *NodeRenderer) renderParagraph(ctx context.Context, w util.BufWriter, s
 	})
 
 	ctx = templ.WithChildren(ctx, children)
-	cmp, err := nr.builder.Build(component, n.Attributes())
+	cmp, err := nr.builder.Build(ctx, component, n.Attributes())
 	if err != nil {
 		return ast.WalkStop, err
 	}
@@ -420,7 +492,7 @@ func (nr *NodeRenderer) renderHeading(ctx context.Context, w util.BufWriter, sou
 	})
 
 	ctx = templ.WithChildren(ctx, children)
-	cmp, err := nr.builder.Build(component, n.Attributes())
+	cmp, err := nr.builder.Build(ctx, component, n.Attributes())
 	if err != nil {
 		return ast.WalkStop, err
 	}
@@ -456,20 +528,38 @@ func (nr *NodeRenderer) renderComponent(ctx context.Context, w io.Writer, node p
 	case *parser.ComponentNode:
 		return nr.renderComponentNode(ctx, w, n, parentNS)
 	case *parser.TextNode:
-		return goldmark.Convert([]byte(n.Value), w)
+		value, err := interpolate(ctx, n.Value)
+		if err != nil {
+			return err
+		}
+		return goldmark.Convert([]byte(value), w)
 	default:
 		return fmt.Errorf("unsupported node type: %T", node)
 	}
 }
 
-// renderComponentNode handles specific component node rendering
+// renderComponentNode handles specific component node rendering.
+// Errors and panics are reported as a *RenderError carrying the chain of components.
 func (nr *NodeRenderer) renderComponentNode(
 	ctx context.Context, w io.Writer,
 	node *parser.ComponentNode,
 	parentNS registry.Layout,
-) error {
-	if node.Name == "Slot" {
-		return nr.renderSlot(ctx, w)
+) (err error) {
+	ctx = withPosition(withComponent(ctx, node.Name), node.Span().Start)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if err != nil {
+			err = newRenderError(ctx, err)
+		}
+	}()
+
+	if node.Name == slotComponent {
+		return nr.renderSlot(ctx, w, node)
+	}
+	if def, ok := getDefinitions(ctx)[node.Name]; ok {
+		return nr.renderFragment(ctx, w, def, node)
 	}
 
 	cmpFunc, err := nr.builder.GetComponent(node.Name, parentNS)
@@ -477,7 +567,7 @@ func (nr *NodeRenderer) renderComponentNode(
 		return err
 	}
 
-	component, err := nr.builder.Build(cmpFunc, node.Attributes())
+	component, err := nr.builder.Build(ctx, cmpFunc, node.Attributes())
 	if err != nil {
 		return fmt.Errorf("failed to build component %s: %w", node.Name, err)
 	}
@@ -494,38 +584,51 @@ func (nr *NodeRenderer) renderComponentNode(
 	return component.Render(templ.WithChildren(ctx, children), w)
 }
 
-// renderSlot handles slot rendering
-func (nr *NodeRenderer) renderSlot(ctx context.Context, w io.Writer) error {
-	component, ok := GetSlot(ctx)
-	if !ok {
-		return errors.New("slot not found")
+// renderSlot renders the page, or the content filling the slot named by the Name property
+// of node. The children of node are rendered when the slot is not filled.
+func (nr *NodeRenderer) renderSlot(ctx context.Context, w io.Writer, node *parser.ComponentNode) error {
+	if len(node.Properties()) == 0 {
+		component, ok := GetSlot(ctx)
+		if !ok {
+			return errors.New("slot not found")
+		}
+		return component.Render(ctx, w)
+	}
+	name, err := slotName(node)
+	if err != nil {
+		return err
+	}
+	if component, ok := GetNamedSlot(ctx, name); ok {
+		return component.Render(ctx, w)
 	}
-	return component.Render(ctx, w)
+	return nr.renderNodes(ctx, w, node.Children(), func(ctx context.Context, w io.Writer, node parser.Node) error {
+		return nr.renderComponent(ctx, w, node, nil)
+	})
 }
 
 // renderChildren handles rendering of child nodes
 func (nr *NodeRenderer) renderChildren(node *parser.ComponentNode, namespace registry.Layout) (templ.Component, error) {
-	var components []templ.Component
 	ns, err := namespace.Namespace(node.Name)
 	if err != nil {
 		ns, err = nr.layout.Namespace(node.Name)
 	}
-	for _, child := range node.Children() {
+	render := func(ctx context.Context, w io.Writer, child parser.Node) error {
 		switch c := child.(type) {
 		case *parser.ComponentNode:
-			comp := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
-				return nr.renderComponent(ctx, w, c, ns)
-			})
-			components = append(components, comp)
+			return nr.renderComponent(ctx, w, c, ns)
 		case *parser.TextNode:
-			comp := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
-				return New(nr.layout).Convert([]byte(c.Value), w)
-			})
-			components = append(components, comp)
+			value, err := interpolate(ctx, c.Value)
+			if err != nil {
+				return err
+			}
+			return New(nr.layout).Convert([]byte(value), w)
+		default:
+			return nil
 		}
 	}
-
-	return templ.Join(components...), nil
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		return nr.renderNodes(ctx, w, node.Children(), render)
+	}), nil
 }
 
 // Helper function to get a buffered writer
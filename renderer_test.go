@@ -0,0 +1,136 @@
+package margo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/iota-uz/margo/registry"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRenderDirectives(t *testing.T) {
+	layout := registry.NewLayout("test")
+	layout.Register("Badge", func(props struct {
+		Color string
+		Attrs templ.Attributes
+	}) templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			fmt.Fprintf(w, `<span class="badge-%s" title="%v">`, props.Color, props.Attrs["title"])
+			if err := templ.GetChildren(ctx).Render(ctx, w); err != nil {
+				return err
+			}
+			_, err := io.WriteString(w, "</span>")
+			return err
+		})
+	})
+	layout.Register("Callout", func(props struct{ Type string }) templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			fmt.Fprintf(w, `<aside class="%s">`, props.Type)
+			if err := templ.GetChildren(ctx).Render(ctx, w); err != nil {
+				return err
+			}
+			_, err := io.WriteString(w, "</aside>")
+			return err
+		})
+	})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "inline",
+			input:    `Released :Badge[New]{color="red" title="Fresh"} today at 12:30.`,
+			expected: []string{`Released <span class="badge-red" title="Fresh">New</span> today at 12:30.`},
+		},
+		{
+			name:     "inline without label",
+			input:    `Status: :Badge{color="green"}`,
+			expected: []string{`Status: <span class="badge-green" title="<nil>"></span>`},
+		},
+		{
+			name:     "container",
+			input:    ":::Callout{type=\"warning\"}\nMind the **gap**\n:::\n\nAfter",
+			expected: []string{`<aside class="warning"><p>Mind the <strong>gap</strong></p>`, "</aside>", "<p>After</p>"},
+		},
+		{
+			name:  "nested containers",
+			input: "::::Callout{type=\"outer\"}\n:::Callout{type=\"inner\"}\nInner\n:::\nOuter\n::::\n",
+			expected: []string{
+				`<aside class="outer"><aside class="inner"><p>Inner</p>`,
+				"</aside><p>Outer</p>",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := New(layout).Convert([]byte(tt.input), &buf); err != nil {
+				t.Fatalf("Convert() failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(buf.String(), expected) {
+					t.Errorf("expected output to contain %q, got %q", expected, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRenderDirectiveUnknownComponent(t *testing.T) {
+	var buf bytes.Buffer
+	err := New(registry.NewLayout("test")).Convert([]byte("A :Tooltip[hint] here"), &buf)
+	var renderErr *RenderError
+	if !errors.As(err, &renderErr) || len(renderErr.Components) != 1 || renderErr.Components[0] != "Tooltip" {
+		t.Errorf("expected a *RenderError for Tooltip, got %v", err)
+	}
+}
+
+func TestRenderComponentError(t *testing.T) {
+	errNotFound := errors.New("post not found")
+	layout := registry.NewLayout("test")
+	layout.Register("Card", func() templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			return templ.GetChildren(ctx).Render(ctx, w)
+		})
+	})
+	layout.Register("Post", func(ctx context.Context, props struct{ Slug string }) (templ.Component, error) {
+		return nil, errNotFound
+	})
+	var buf bytes.Buffer
+	err := New(layout).Convert([]byte("```margo\n\\Card\n    \\Post Slug: \"missing\"\n```\n"), &buf)
+	var renderErr *RenderError
+	if !errors.As(err, &renderErr) {
+		t.Fatalf("expected a *RenderError, got %v", err)
+	}
+	if !errors.Is(err, errNotFound) {
+		t.Errorf("expected the component error to be wrapped, got %v", err)
+	}
+	if got := strings.Join(renderErr.Components, " > "); got != "Card > Post" {
+		t.Errorf("expected the component chain Card > Post, got %q", got)
+	}
+}
+
+func TestRenderRawString(t *testing.T) {
+	layout := registry.NewLayout("test")
+	layout.Register("Code", func(props struct{ Source string }) templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			_, err := io.WriteString(w, props.Source)
+			return err
+		})
+	})
+	var buf bytes.Buffer
+	input := "```margo\n\\Code\n    Source: \"\"\"\n        Hello {{ name }}\n        \"\"\"\n```\n"
+	if err := New(layout).Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("Convert() failed: %v", err)
+	}
+	if got := buf.String(); got != "Hello {{ name }}" {
+		t.Errorf("expected the raw string as written, got %q", got)
+	}
+}
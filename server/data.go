@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DataDir is the directory of the content root holding data files.
+// It is not indexed as pages, its files are available as {{ data.* }} in margo blocks.
+// Setting it to "" disables data files, a root "data" directory being served again.
+var DataDir = "data"
+
+// LoadData reads the YAML and JSON files of DataDir into a map keyed by file name,
+// nested by directory, and returns the paths of the files read.
+// Ex.: "data/team.yaml" -> data["team"], "data/blog/authors.json" -> data["blog"]["authors"]
+func LoadData(fsys fs.FS) (map[string]any, []string, error) {
+	data := map[string]any{}
+	if DataDir == "" {
+		return data, nil, nil
+	}
+	var files []string
+	err := fs.WalkDir(fsys, DataDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == DataDir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(path.Ext(p))
+		if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		var v any
+		if ext == ".json" {
+			err = json.Unmarshal(content, &v)
+		} else {
+			err = yaml.Unmarshal(content, &v)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", p, err)
+		}
+		parent := data
+		rel := strings.TrimPrefix(p, DataDir+"/")
+		dirs := strings.Split(path.Dir(rel), "/")
+		for _, dir := range dirs {
+			if dir == "." {
+				continue
+			}
+			child, ok := parent[dir].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				parent[dir] = child
+			}
+			parent = child
+		}
+		parent[StripExt(path.Base(rel))] = v
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, files, nil
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"sync"
+
+	"github.com/iota-uz/margo/registry"
+	"github.com/iota-uz/margo/seo"
+	"github.com/iota-uz/margo/types"
+)
+
+var (
+	// NotFoundFile is the page rendered when no route matches the request.
+	NotFoundFile = "404.md"
+	// ErrorFile is the page rendered when a page fails to load or render.
+	ErrorFile = "500.md"
+)
+
+// Option configures the handler returned by Handler.
+type Option func(*handler)
+
+// WithNotFoundPage overrides the page rendered with a 404 status.
+// Ex.: "errors/not-found.md"
+func WithNotFoundPage(path string) Option {
+	return func(h *handler) {
+		h.notFoundPage = path
+	}
+}
+
+// WithErrorPage overrides the page rendered with a 500 status.
+// Ex.: "errors/internal.md"
+func WithErrorPage(path string) Option {
+	return func(h *handler) {
+		h.errorPage = path
+	}
+}
+
+// WithLiveIndex re-indexes the filesystem, reads the data files and partials on every request instead of once.
+// Useful while editing content, too slow for production.
+func WithLiveIndex() Option {
+	return func(h *handler) {
+		h.liveIndex = true
+	}
+}
+
+// WithSite sets the site configuration, available as {{ site.* }} in margo blocks.
+// Ex.: map[string]any{"name": "Margo"}
+func WithSite(site map[string]any) Option {
+	return func(h *handler) {
+		h.site = site
+	}
+}
+
+// Handler returns an http.Handler that serves the site stored in fsys.
+// Requests are routed by the URLs computed by IndexDirectory, markdown pages are
+// rendered on demand through layouts.Base and everything else is served as is.
+func Handler(fsys fs.FS, reg registry.Registry, opts ...Option) http.Handler {
+	h := &handler{
+		fsys:         fsys,
+		registry:     reg,
+		notFoundPage: NotFoundFile,
+		errorPage:    ErrorFile,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+type routeTable struct {
+	byURL  map[string]*FsItem
+	byPath map[string]*FsItem
+	// loader shares the data files and partials between the pages of the index.
+	loader *MarkdownLoader
+}
+
+type handler struct {
+	fsys         fs.FS
+	registry     registry.Registry
+	notFoundPage string
+	errorPage    string
+	liveIndex    bool
+	site         map[string]any
+
+	mu     sync.Mutex
+	routes *routeTable
+}
+
+func (h *handler) index() (*routeTable, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.routes != nil && !h.liveIndex {
+		return h.routes, nil
+	}
+	items, err := IndexDirectory(h.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	routes := &routeTable{
+		byURL:  make(map[string]*FsItem, len(items)),
+		byPath: make(map[string]*FsItem, len(items)),
+		loader: NewLoader(h.fsys),
+	}
+	for _, item := range items {
+		routes.byPath[item.Path] = item
+		// error pages are only rendered with their status code
+		if item.Path == h.notFoundPage || item.Path == h.errorPage {
+			continue
+		}
+		routes.byURL[item.URL] = item
+	}
+	h.routes = routes
+	return routes, nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	routes, err := h.index()
+	if err != nil {
+		h.serveError(w, r, nil, err)
+		return
+	}
+	item, ok := routes.byURL[path.Clean("/"+r.URL.Path)]
+	if !ok {
+		h.serveNotFound(w, r, routes)
+		return
+	}
+	if item.IsStatic {
+		h.serveStatic(w, r, routes, item)
+		return
+	}
+	if err := h.servePage(w, r, routes, item, http.StatusOK); err != nil {
+		h.serveError(w, r, routes, err)
+	}
+}
+
+// servePage renders the page into a buffer first so that a failing page
+// can still be answered with an error page.
+func (h *handler) servePage(w http.ResponseWriter, r *http.Request, routes *routeTable, item *FsItem, status int) error {
+	page, err := routes.loader.Load(item, h.registry)
+	if err != nil {
+		return err
+	}
+	pageCtx, err := types.NewPageCtx(r, seo.FromPageMeta(page.Meta()))
+	if err != nil {
+		return err
+	}
+	pageCtx.Meta = page.Meta()
+	pageCtx.Site = h.site
+	if dataPage, ok := page.(DataPage); ok {
+		pageCtx.Data = dataPage.Data()
+	}
+	var buf bytes.Buffer
+	if err := RenderPage(r.Context(), &buf, page, pageCtx); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Printf("could not write page %s: %v", item.Path, err)
+	}
+	return nil
+}
+
+func (h *handler) serveStatic(w http.ResponseWriter, r *http.Request, routes *routeTable, item *FsItem) {
+	f, err := h.fsys.Open(item.Path)
+	if err != nil {
+		h.serveError(w, r, routes, err)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		h.serveError(w, r, routes, err)
+		return
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			h.serveError(w, r, routes, err)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+	// ServeContent derives the Content-Type from the file extension
+	// and falls back to sniffing the content.
+	http.ServeContent(w, r, item.Path, stat.ModTime(), content)
+}
+
+func (h *handler) serveNotFound(w http.ResponseWriter, r *http.Request, routes *routeTable) {
+	item, ok := routes.byPath[h.notFoundPage]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.servePage(w, r, routes, item, http.StatusNotFound); err != nil {
+		h.serveError(w, r, routes, err)
+	}
+}
+
+func (h *handler) serveError(w http.ResponseWriter, r *http.Request, routes *routeTable, err error) {
+	log.Printf("could not serve %s: %v", r.URL.Path, err)
+	if routes != nil {
+		if item, ok := routes.byPath[h.errorPage]; ok {
+			pageErr := h.servePage(w, r, routes, item, http.StatusInternalServerError)
+			if pageErr == nil {
+				return
+			}
+			log.Printf("could not render error page %s: %v", item.Path, pageErr)
+		}
+	}
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/a-h/templ"
+
+	"github.com/iota-uz/margo/registry"
+)
+
+func newTestRegistry() registry.Registry {
+	return registry.New().RegisterLayout(registry.NewLayout("blog"))
+}
+
+func TestHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.md":             {Data: []byte("---\nlayout: blog\ntitle: Home\n---\n# Welcome\n")},
+		"docs/intro.md":        {Data: []byte("---\nlayout: blog\n---\nIntro page\n")},
+		"404.md":               {Data: []byte("---\nlayout: blog\n---\nNothing here\n")},
+		"500.md":               {Data: []byte("---\nlayout: blog\n---\nSomething broke\n")},
+		"assets/css/main.css":  {Data: []byte("body{}")},
+		"broken.md":            {Data: []byte("---\nlayout: missing\n---\nBroken\n")},
+		"assets/img/logo.json": {Data: []byte("{}")},
+	}
+	h := Handler(fsys, newTestRegistry())
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{path: "/", status: http.StatusOK, contentType: "text/html; charset=utf-8", body: "Welcome"},
+		{path: "/docs/intro", status: http.StatusOK, contentType: "text/html; charset=utf-8", body: "Intro page"},
+		{path: "/docs/intro/", status: http.StatusOK, contentType: "text/html; charset=utf-8", body: "Intro page"},
+		{path: "/assets/css/main.css", status: http.StatusOK, contentType: "text/css; charset=utf-8", body: "body{}"},
+		{path: "/assets/img/logo.json", status: http.StatusOK, contentType: "application/json", body: "{}"},
+		{path: "/missing", status: http.StatusNotFound, contentType: "text/html; charset=utf-8", body: "Nothing here"},
+		{path: "/broken", status: http.StatusInternalServerError, body: "Something broke"},
+		{path: "/404", status: http.StatusNotFound, body: "Nothing here"},
+		{path: "/500", status: http.StatusNotFound, body: "Nothing here"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); tt.contentType != "" && ct != tt.contentType {
+				t.Errorf("expected content type %q, got %q", tt.contentType, ct)
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("expected body to contain %q, got %q", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlerWithoutErrorPages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.md": {Data: []byte("---\nlayout: blog\n---\nHome\n")},
+	}
+	h := Handler(fsys, newTestRegistry())
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestHandlerInterpolation(t *testing.T) {
+	layout := registry.NewLayout("blog")
+	layout.Register("Hero", func(props struct {
+		Title string
+		Count int
+	}) templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			_, err := fmt.Fprintf(w, "<h1>%s (%d)</h1>", props.Title, props.Count)
+			return err
+		})
+	})
+	reg := registry.New().RegisterLayout(layout)
+	fsys := fstest.MapFS{
+		"index.md": {Data: []byte("---\nlayout: blog\ntitle: Home\ncount: 3\n---\n" +
+			"```margo\n\\Hero\n\tTitle: \"{{ page.title }} | {{ site.name }}\"\n\tCount: {{ page.count }}\n```\n")},
+		"broken.md": {Data: []byte("---\nlayout: blog\n---\n```margo\n\\Hero\n\tTitle: {{ page.missing }}\n```\n")},
+		"quoted.md": {Data: []byte("---\nlayout: blog\n---\n```margo\n\\Hero\n\tTitle: \"Hi {{ page.missing }}\"\n```\n")},
+		"sample.md": {Data: []byte("---\nlayout: blog\n---\n```margo\n\\Hero\n\tTitle: \"Hello {{ name }}\"\n\tCount: 1\nWrite {{ user.name }} in a template\n```\n")},
+	}
+	h := Handler(fsys, reg, WithSite(map[string]any{"name": "Margo"}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "<h1>Home | Margo (3)</h1>") {
+		t.Errorf("expected interpolated title, got %q", body)
+	}
+
+	for _, url := range []string{"/broken", "/quoted"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d for an unknown variable of %s, got %d", http.StatusInternalServerError, url, rec.Code)
+		}
+	}
+
+	// expressions that are not about the page, site or data are kept as written
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sample", nil))
+	body := rec.Body.String()
+	for _, expected := range []string{"<h1>Hello {{ name }} (1)</h1>", "Write {{ user.name }} in a template"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q to be kept, got %d %q", expected, rec.Code, body)
+		}
+	}
+}
+
+func TestHandlerControlFlow(t *testing.T) {
+	layout := registry.NewLayout("blog")
+	layout.Register("Card", func(props struct {
+		Name string
+		Role string
+	}) templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			_, err := fmt.Fprintf(w, "<card>%s, %s</card>", props.Name, props.Role)
+			return err
+		})
+	})
+	reg := registry.New().RegisterLayout(layout)
+	page := "---\nlayout: blog\ndraft: true\nlive: false\n---\n```margo\n" +
+		"\\If Cond: {{ page.draft }}\n\tDraft notice\n\\Else\n\tPublished notice\n" +
+		"\\If Cond: \"{{ page.live }}\"\n\tLive notice\n\\Else\n\tOffline notice\n" +
+		"\\If Cond: {{ page.missing }}\n\tMissing notice\n\\Else\n\tFallback notice\n" +
+		"\\Each Items: {{ data.team }} As: \"member\"\n\t\\Card\n\t\tName: {{ member.name }}\n\t\tRole: \"{{ member.role }} #{{ loop.index }}\"\n" +
+		"```\n"
+	fsys := fstest.MapFS{
+		"index.md":       {Data: []byte(page)},
+		"data/team.yaml": {Data: []byte("- name: Ada\n  role: CTO\n- name: Linus\n  role: Engineer\n")},
+	}
+	h := Handler(fsys, reg)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	for _, expected := range []string{"Draft notice", "Offline notice", "Fallback notice", "<card>Ada, CTO #0</card>", "<card>Linus, Engineer #1</card>"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected body to contain %q, got %q", expected, body)
+		}
+	}
+	for _, unexpected := range []string{"Published notice", "Live notice", "Missing notice"} {
+		if strings.Contains(body, unexpected) {
+			t.Errorf("expected body not to contain %q", unexpected)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/data/team.yaml", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected data files not to be served, got status %d", rec.Code)
+	}
+}
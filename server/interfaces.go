@@ -33,7 +33,36 @@ type Page interface {
 	Meta() map[string]any
 }
 
-var _ Page = &page{}
+// DataPage is implemented by pages loaded along with the data files, see LoadData.
+// Check for it with a type assertion, like DependentPage.
+type DataPage interface {
+	Page
+
+	// Data returns the content of the data files.
+	Data() map[string]any
+}
+
+// DependentPage is implemented by pages knowing the source files they are built from.
+// It is kept apart from Page so that other implementations of Page keep working,
+// check for it with a type assertion.
+type DependentPage interface {
+	Page
+
+	// Dependencies returns the source files the rendered page depends on,
+	// including the layout files looked up that do not exist.
+	// Ex.: []string{"docs/introduction.md", "docs/layout.md", "layout.md"}
+	Dependencies() []string
+
+	// DependencyDirs returns the directories whose files the rendered page depends on,
+	// a file added to them changing the page.
+	// Ex.: []string{"data", "partials"}
+	DependencyDirs() []string
+}
+
+var (
+	_ DependentPage = &page{}
+	_ DataPage      = &page{}
+)
 
 type page struct {
 	name      string
@@ -41,6 +70,9 @@ type page struct {
 	url       string
 	component templ.Component
 	meta      map[string]any
+	data      map[string]any
+	deps      []string
+	depDirs   []string
 }
 
 func (f *page) Path() string {
@@ -62,3 +94,15 @@ func (f *page) URL() string {
 func (f *page) Meta() map[string]any {
 	return f.meta
 }
+
+func (f *page) Data() map[string]any {
+	return f.data
+}
+
+func (f *page) Dependencies() []string {
+	return f.deps
+}
+
+func (f *page) DependencyDirs() []string {
+	return f.depDirs
+}
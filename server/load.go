@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/a-h/templ"
 	"github.com/yuin/goldmark"
@@ -16,6 +19,7 @@ import (
 	"github.com/yuin/goldmark/parser"
 
 	"github.com/iota-uz/margo"
+	margoparser "github.com/iota-uz/margo/parser"
 	"github.com/iota-uz/margo/registry"
 )
 
@@ -43,10 +47,54 @@ func (e ErrMissingLayout) Error() string {
 		strings.Join(e.AvailableLayouts, ", "))
 }
 
+// ErrUnknownSlot is returned when a page fills a slot its layout file does not have
+type ErrUnknownSlot struct {
+	Slot           string
+	AvailableSlots []string
+}
+
+func (e ErrUnknownSlot) Error() string {
+	if len(e.AvailableSlots) == 0 {
+		return fmt.Sprintf("unknown slot %q, the layout files have no named slots", e.Slot)
+	}
+	return fmt.Sprintf("unknown slot %q. Must be one of the following: %s",
+		e.Slot, strings.Join(e.AvailableSlots, ", "))
+}
+
+// ErrInvalidSlotsType is returned when the slots metadata is not a map of markdown text
+var ErrInvalidSlotsType = errors.New("slots must map slot names to markdown text")
+
+// ErrInvalidLayoutFileType is returned when the layout_file metadata is neither a path nor false
+var ErrInvalidLayoutFileType = errors.New("layout_file must be the path of a layout file or false")
+
+// ErrLayoutCycle is returned when layout files render inside each other
+type ErrLayoutCycle struct {
+	Chain []string
+}
+
+func (e ErrLayoutCycle) Error() string {
+	return fmt.Sprintf("layout cycle: %s", strings.Join(e.Chain, " > "))
+}
+
+// ErrIncludeCycle is returned when markdown files include each other
+type ErrIncludeCycle struct {
+	Chain []string
+}
+
+func (e ErrIncludeCycle) Error() string {
+	return fmt.Sprintf("include cycle: %s", strings.Join(e.Chain, " > "))
+}
+
 var (
 	LayoutFile = "layout.md"
+	// PartialsDir holds the markdown files declaring fragments with \Define,
+	// or included with \Include, it is not served.
+	// Setting it to "" disables partials, a root "partials" directory being served again.
+	PartialsDir = "partials"
 )
 
+// NewLoader returns a loader of the pages of fsys.
+// The data files and partials are read once per loader, a new loader picks up their changes.
 func NewLoader(fsys fs.FS) *MarkdownLoader {
 	return &MarkdownLoader{
 		fs: fsys,
@@ -69,6 +117,24 @@ func GetMeta(content []byte) (map[string]any, error) {
 
 type MarkdownLoader struct {
 	fs fs.FS
+
+	dataOnce  sync.Once
+	data      map[string]any
+	dataFiles []string
+	dataErr   error
+
+	partialsOnce sync.Once
+	partialDefs  map[string]*margoparser.ComponentNode
+	partialDeps  []string
+	partialsErr  error
+}
+
+// loadData returns the data files of the loader, see LoadData.
+func (m *MarkdownLoader) loadData() (map[string]any, []string, error) {
+	m.dataOnce.Do(func() {
+		m.data, m.dataFiles, m.dataErr = LoadData(m.fs)
+	})
+	return m.data, m.dataFiles, m.dataErr
 }
 
 func (m *MarkdownLoader) GetMeta(path string) (map[string]any, error) {
@@ -116,21 +182,42 @@ func (m *MarkdownLoader) Load(item *FsItem, reg registry.Registry) (Page, error)
 	if err != nil {
 		return nil, err
 	}
+	data, dataFiles, err := m.loadData()
+	if err != nil {
+		return nil, err
+	}
+	layoutFiles, lookups, err := m.loadLayoutFiles(item, fileMeta)
+	if err != nil {
+		return nil, err
+	}
+	// syntax errors are reported when rendering the page
+	content, _ := margo.ExportPage(fileBytes, item.Path)
+	defs, fragmentDeps, err := m.loadFragments(content, item.Path, layoutFiles)
+	if err != nil {
+		return nil, err
+	}
 	margoConverter := margo.New(layout)
-	var component templ.Component
-	if item.Layout == "" {
-		component = margoConverter.ConvertToTempl(fileBytes)
-	} else {
-		layoutBytes, err := fs.ReadFile(m.fs, item.Layout)
-		if err != nil {
-			return nil, err
+	deps := []string{item.Path}
+	slots, err := loadSlots(item, fileMeta, content, layoutFiles, layout, margoConverter)
+	if err != nil {
+		return nil, err
+	}
+	component := withSourcePath(margoConverter.ConvertToTempl(fileBytes, margo.WithFile(item.Path)), item.Path)
+	for _, file := range layoutFiles {
+		deps = append(deps, file.path)
+		component = withLayoutFile(component, file, margoConverter)
+	}
+	if len(slots) > 0 {
+		component = withNamedSlots(component, slots)
+	}
+	component = withFragments(component, defs, func(path string) ([]byte, error) {
+		return fs.ReadFile(m.fs, path)
+	})
+	// layout files looked up but missing change the page once created
+	for _, dep := range append(fragmentDeps, lookups...) {
+		if !slices.Contains(deps, dep) {
+			deps = append(deps, dep)
 		}
-		component = templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
-			return margoConverter.ConvertToTempl(layoutBytes).Render(
-				margo.WithSlot(ctx, margoConverter.ConvertToTempl(fileBytes)),
-				w,
-			)
-		})
 	}
 	name := StripExt(filepath.Base(item.Path))
 	return &page{
@@ -139,23 +226,365 @@ func (m *MarkdownLoader) Load(item *FsItem, reg registry.Registry) (Page, error)
 		url:       item.URL,
 		component: component,
 		meta:      fileMeta,
+		data:      data,
+		deps:      append(deps, dataFiles...),
+		depDirs:   dependencyDirs(),
 	}, nil
 }
 
+// dependencyDirs returns the directories every page depends on, whose files are looked up
+// rather than referenced: DataDir and PartialsDir.
+func dependencyDirs() []string {
+	var dirs []string
+	for _, dir := range []string{DataDir, PartialsDir} {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// layoutFile is a layout file wrapping a page.
+type layoutFile struct {
+	path   string
+	source []byte
+	// content is nil when the layout file fails to parse, the rendering reports it.
+	content *margo.Page
+}
+
+// loadLayoutFiles returns the layout files wrapping the page, innermost first, along with
+// the paths looked up to find them, see layoutCandidates.
+// A file, page or layout file, renders inside the nearest layout file of its directory
+// or the directories above it, unless its front-matter sets another one with layout_file
+// or opts out with layout_file: false.
+func (m *MarkdownLoader) loadLayoutFiles(item *FsItem, fileMeta map[string]any) ([]*layoutFile, []string, error) {
+	next, err := layoutFileOverride(fileMeta, item.Layout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", item.Path, err)
+	}
+	var lookups []string
+	if _, ok := fileMeta["layout_file"]; !ok {
+		lookups = append(lookups, layoutCandidates(item.Path)...)
+	}
+	var files []*layoutFile
+	chain := []string{item.Path}
+	for next != "" {
+		if slices.Contains(chain, next) {
+			return nil, nil, &ErrLayoutCycle{Chain: append(chain, next)}
+		}
+		chain = append(chain, next)
+		source, err := fs.ReadFile(m.fs, next)
+		if err != nil {
+			return nil, nil, err
+		}
+		layoutMeta, err := GetMeta(source)
+		if err != nil {
+			return nil, nil, err
+		}
+		file := &layoutFile{path: next, source: source}
+		file.content, _ = margo.ExportPage(source, next)
+		files = append(files, file)
+		if _, ok := layoutMeta["layout_file"]; !ok {
+			lookups = append(lookups, layoutCandidates(next)...)
+		}
+		if next, err = layoutFileOverride(layoutMeta, findLayoutFile(m.fs, next)); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file.path, err)
+		}
+	}
+	return files, lookups, nil
+}
+
+// layoutFileOverride returns the layout file set by the layout_file front-matter,
+// or fallback when there is none. It is empty for layout_file: false.
+func layoutFileOverride(fileMeta map[string]any, fallback string) (string, error) {
+	value, ok := fileMeta["layout_file"]
+	if !ok {
+		return fallback, nil
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		if !v {
+			return "", nil
+		}
+	}
+	return "", ErrInvalidLayoutFileType
+}
+
+// findLayoutFile returns the nearest layout file of the directory of path or the
+// directories above it, a layout file not wrapping itself. It is empty when there is none.
+func findLayoutFile(fsys fs.FS, path string) string {
+	for _, candidate := range layoutCandidates(path) {
+		if stat, err := fs.Stat(fsys, candidate); err == nil && !stat.IsDir() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// layoutCandidates returns the paths of the layout files that may wrap path, nearest first.
+// Ex.: "docs/api/page.md" -> "docs/api/layout.md", "docs/layout.md", "layout.md"
+func layoutCandidates(path string) []string {
+	dir := filepath.Dir(path)
+	if filepath.Base(path) == LayoutFile {
+		if dir == "." {
+			return nil
+		}
+		dir = filepath.Dir(dir)
+	}
+	var res []string
+	for {
+		res = append(res, filepath.Join(dir, LayoutFile))
+		if dir == "." {
+			return res
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// withLayoutFile renders file with component as its slot.
+func withLayoutFile(component templ.Component, file *layoutFile, md margo.Markdown) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		layoutComponent := withSourcePath(md.ConvertToTempl(file.source, margo.WithFile(file.path)), file.path)
+		return layoutComponent.Render(margo.WithSlot(ctx, component), w)
+	})
+}
+
+// withNamedSlots makes the content filling the named slots of the layout files available to component.
+func withNamedSlots(component templ.Component, slots map[string]templ.Component) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		for name, slot := range slots {
+			ctx = margo.WithNamedSlot(ctx, name, slot)
+		}
+		return component.Render(ctx, w)
+	})
+}
+
+// loadFragments collects the fragments declared with \Define in PartialsDir, the layout files
+// from the outermost and the page at path, in this order, a fragment replacing the one
+// of the same name before it. It returns them along with the files they come from and
+// the files included. The page is nil when it fails to parse, the rendering reports it.
+func (m *MarkdownLoader) loadFragments(content *margo.Page, path string, layoutFiles []*layoutFile) (map[string]*margoparser.ComponentNode, []string, error) {
+	partialDefs, partialDeps, err := m.loadPartials()
+	if err != nil {
+		return nil, nil, err
+	}
+	var paths []string
+	var sources []*margo.Page
+	for i := len(layoutFiles) - 1; i >= 0; i-- {
+		paths = append(paths, layoutFiles[i].path)
+		sources = append(sources, layoutFiles[i].content)
+	}
+	paths = append(paths, path)
+	sources = append(sources, content)
+	defs := maps.Clone(partialDefs)
+	deps, err := m.collectDefinitions(defs, paths, sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	return defs, append(slices.Clip(partialDeps), deps...), nil
+}
+
+// loadPartials returns the fragments declared in PartialsDir along with the files they come
+// from and the files included, see loadFragments. The partials are parsed once per loader.
+func (m *MarkdownLoader) loadPartials() (map[string]*margoparser.ComponentNode, []string, error) {
+	m.partialsOnce.Do(func() {
+		partials, err := m.partials()
+		if err != nil {
+			m.partialsErr = err
+			return
+		}
+		sources := make([]*margo.Page, len(partials))
+		for i, partial := range partials {
+			if sources[i], err = m.export(partial); err != nil {
+				m.partialsErr = err
+				return
+			}
+		}
+		m.partialDefs = make(map[string]*margoparser.ComponentNode)
+		m.partialDeps, m.partialsErr = m.collectDefinitions(m.partialDefs, partials, sources)
+	})
+	return m.partialDefs, m.partialDeps, m.partialsErr
+}
+
+// collectDefinitions copies the fragments of sources, found at paths, into defs in order.
+// It returns the files declaring fragments and the files included.
+func (m *MarkdownLoader) collectDefinitions(defs map[string]*margoparser.ComponentNode, paths []string, sources []*margo.Page) ([]string, error) {
+	var deps []string
+	for i, source := range sources {
+		if source == nil {
+			continue
+		}
+		fileDefs, err := source.Definitions()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", paths[i], err)
+		}
+		maps.Copy(defs, fileDefs)
+		if len(fileDefs) > 0 {
+			deps = append(deps, paths[i])
+		}
+		included, err := m.includes(paths[i], source, nil)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, included...)
+	}
+	return deps, nil
+}
+
+// loadSlots returns the content of the named slots of the layout files filled by the page,
+// with \Fill blocks or the "slots" front-matter, rendered with the layout of the page.
+func loadSlots(
+	item *FsItem,
+	fileMeta map[string]any,
+	content *margo.Page,
+	layoutFiles []*layoutFile,
+	layout registry.Layout,
+	md margo.Markdown,
+) (map[string]templ.Component, error) {
+	slots := make(map[string]templ.Component)
+	if content != nil {
+		fills, err := content.Fills()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", item.Path, err)
+		}
+		renderer := margo.NewRenderer(layout)
+		for name, fill := range fills {
+			slots[name] = withSourcePath(renderer.RenderToTempl(nil, fill), item.Path)
+		}
+	}
+	if metaSlots, ok := fileMeta["slots"]; ok {
+		values, ok := metaSlots.(map[any]any)
+		if !ok {
+			return nil, ErrInvalidSlotsType
+		}
+		for key, value := range values {
+			name := fmt.Sprint(key)
+			text, ok := value.(string)
+			if !ok {
+				return nil, ErrInvalidSlotsType
+			}
+			if _, ok := slots[name]; ok {
+				return nil, fmt.Errorf("slot %q is filled twice, by the front-matter and a \\Fill", name)
+			}
+			slots[name] = withSourcePath(md.ConvertToTempl([]byte(text), margo.WithFile(item.Path)), item.Path)
+		}
+	}
+	if len(slots) == 0 {
+		return slots, nil
+	}
+	var available []string
+	for _, file := range layoutFiles {
+		if file.content == nil {
+			return slots, nil
+		}
+		for _, name := range file.content.Slots() {
+			if !slices.Contains(available, name) {
+				available = append(available, name)
+			}
+		}
+	}
+	for name := range slots {
+		if !slices.Contains(available, name) {
+			return nil, &ErrUnknownSlot{Slot: name, AvailableSlots: available}
+		}
+	}
+	return slots, nil
+}
+
+// partials returns the markdown files of PartialsDir.
+func (m *MarkdownLoader) partials() ([]string, error) {
+	if PartialsDir == "" {
+		return nil, nil
+	}
+	var res []string
+	err := fs.WalkDir(m.fs, PartialsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if !d.IsDir() && strings.ToLower(filepath.Ext(path)) == ".md" {
+			res = append(res, path)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// includes returns the files included by content, found at path, and by the files it includes.
+// chain holds the files including path.
+func (m *MarkdownLoader) includes(path string, content *margo.Page, chain []string) ([]string, error) {
+	chain = append(slices.Clip(chain), path)
+	var res []string
+	for _, included := range content.Includes() {
+		if slices.Contains(chain, included) {
+			return nil, &ErrIncludeCycle{Chain: append(chain, included)}
+		}
+		includedContent, err := m.export(included)
+		if err != nil {
+			return nil, err
+		}
+		nested, err := m.includes(included, includedContent, chain)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, included)
+		res = append(res, nested...)
+	}
+	return res, nil
+}
+
+// export reads the markdown file at path, see margo.ExportPage.
+func (m *MarkdownLoader) export(path string) (*margo.Page, error) {
+	fileBytes, err := fs.ReadFile(m.fs, path)
+	if err != nil {
+		return nil, err
+	}
+	return margo.ExportPage(fileBytes, path)
+}
+
+// withFragments makes the fragments and the files of \Include available to component.
+func withFragments(component templ.Component, defs map[string]*margoparser.ComponentNode, includer margo.Includer) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		ctx = margo.WithDefinitions(ctx, defs)
+		return component.Render(margo.WithIncluder(ctx, includer), w)
+	})
+}
+
+// withSourcePath sets the path of the markdown file on render errors of component.
+func withSourcePath(component templ.Component, path string) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		err := component.Render(ctx, w)
+		var renderErr *margo.RenderError
+		if errors.As(err, &renderErr) && renderErr.Path == "" {
+			renderErr.Path = path
+		}
+		return err
+	})
+}
+
 type FsItem struct {
 	IsStatic bool
 	Path     string
-	Layout   string
-	URL      string
+	// Layout is the nearest layout file of the page, in its directory or above.
+	Layout string
+	URL    string
 }
 
 func IndexDirectory(fsys fs.FS, dir string) ([]*FsItem, error) {
+	return indexDirectory(fsys, dir, findLayoutFile(fsys, dir))
+}
+
+// indexDirectory indexes dir, layout being the layout file of the directories above it.
+func indexDirectory(fsys fs.FS, dir string, layout string) ([]*FsItem, error) {
 	var result []*FsItem
 	entries, err := fs.ReadDir(fsys, dir)
 	if err != nil {
 		return nil, err
 	}
-	var layout string
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
@@ -166,8 +595,11 @@ func IndexDirectory(fsys fs.FS, dir string) ([]*FsItem, error) {
 	}
 	for _, entry := range entries {
 		if entry.IsDir() {
+			if dir == "." && (entry.Name() == DataDir || entry.Name() == PartialsDir) {
+				continue
+			}
 			fullPath := filepath.Join(dir, entry.Name())
-			children, err := IndexDirectory(fsys, fullPath)
+			children, err := indexDirectory(fsys, fullPath, layout)
 			if err != nil {
 				return nil, err
 			}
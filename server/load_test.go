@@ -0,0 +1,385 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/a-h/templ"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/iota-uz/margo"
+	"github.com/iota-uz/margo/parser"
+	"github.com/iota-uz/margo/registry"
+	"github.com/iota-uz/margo/types"
+)
+
+func TestLoadRenderError(t *testing.T) {
+	layout := registry.NewLayout("blog")
+	layout.Register("HeroV2", func() templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			return templ.GetChildren(ctx).Render(ctx, w)
+		})
+	})
+	layout.Register("ButtonPrimary", func() templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			panic("boom")
+		})
+	})
+	reg := registry.New().RegisterLayout(layout)
+
+	tests := []struct {
+		name       string
+		content    string
+		line       int
+		column     int
+		components []string
+	}{
+		{
+			name:       "component panic",
+			content:    "---\nlayout: blog\n---\n# Title\n\n```margo\n\\HeroV2\n\t\\ButtonPrimary\n```\n",
+			line:       8,
+			column:     2,
+			components: []string{"HeroV2", "ButtonPrimary"},
+		},
+		{
+			name:       "fragment",
+			content:    "---\nlayout: blog\n---\n```margo\n\\Define Name: \"CTA\"\n    \\ButtonPrimary\n\n\\HeroV2\n    \\CTA\n```\n",
+			line:       9,
+			column:     5,
+			components: []string{"HeroV2", "CTA", "ButtonPrimary"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"docs/page.md": {Data: []byte(tt.content)}}
+			page, err := NewLoader(fsys).Load(&FsItem{Path: "docs/page.md", URL: "/docs/page"}, reg)
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			err = page.Render(context.Background(), &bytes.Buffer{})
+			var renderErr *margo.RenderError
+			if !errors.As(err, &renderErr) {
+				t.Fatalf("expected a *margo.RenderError, got %v", err)
+			}
+			if renderErr.Path != "docs/page.md" {
+				t.Errorf("expected path docs/page.md, got %q", renderErr.Path)
+			}
+			if renderErr.Line != tt.line || renderErr.Column != tt.column {
+				t.Errorf("expected position %d:%d, got %d:%d", tt.line, tt.column, renderErr.Line, renderErr.Column)
+			}
+			if len(renderErr.Components) != len(tt.components) {
+				t.Fatalf("expected components %v, got %v", tt.components, renderErr.Components)
+			}
+			for i, c := range tt.components {
+				if renderErr.Components[i] != c {
+					t.Errorf("expected components %v, got %v", tt.components, renderErr.Components)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadSyntaxError(t *testing.T) {
+	reg := registry.New().RegisterLayout(registry.NewLayout("blog"))
+	content := "---\nlayout: blog\n---\n```margo\n\\HeroV2\n   \\ButtonPrimary\n\tTitle: Hello\n```\n"
+	fsys := fstest.MapFS{"docs/page.md": {Data: []byte(content)}}
+	page, err := NewLoader(fsys).Load(&FsItem{Path: "docs/page.md", URL: "/docs/page"}, reg)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	err = page.Render(context.Background(), &bytes.Buffer{})
+	var diags parser.Diagnostics
+	if !errors.As(err, &diags) {
+		t.Fatalf("expected parser.Diagnostics, got %v", err)
+	}
+	expected := []string{
+		"docs/page.md:6:1: error[invalid-indent]: invalid indent of 3 spaces, expected a tab, 2 or 4 spaces",
+		"docs/page.md:7:9: error[expected-value]: expected \" or newline got Hello",
+	}
+	if len(diags) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diags)
+	}
+	for i, diag := range diags {
+		if diag.Error() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], diag.Error())
+		}
+	}
+}
+
+func TestLoadFragments(t *testing.T) {
+	layout := registry.NewLayout("blog")
+	layout.Register("Button", func(props struct{ Text string }) templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			_, err := io.WriteString(w, "<button>"+props.Text+"</button>")
+			return err
+		})
+	})
+	reg := registry.New().RegisterLayout(layout)
+	fsys := fstest.MapFS{
+		"partials/cta.md":  {Data: []byte("```margo\n\\Define Name: \"CTA\"\n    \\Button\n        Text: \"{{ props.text }}!\"\n```\n")},
+		"partials/note.md": {Data: []byte("Shared note\n\n```margo\n\\CTA Text: \"Nested\"\n```\n")},
+		"docs/layout.md":   {Data: []byte("```margo\n\\Define Name: \"Footer\"\n    \\CTA Text: \"Footer\"\n\\Slot\n\\Footer\n```\n")},
+		"docs/page.md":     {Data: []byte("---\nlayout: blog\n---\n```margo\n\\CTA Text: \"Buy\"\n\\Include Path: \"partials/note.md\"\n```\n")},
+	}
+	item := &FsItem{Path: "docs/page.md", Layout: "docs/layout.md", URL: "/docs/page"}
+	page, err := NewLoader(fsys).Load(item, reg)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := page.Render(context.Background(), &buf); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	for _, expected := range []string{"<button>Buy!</button>", "<p>Shared note</p>", "<button>Nested!</button>", "<button>Footer!</button>"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected output to contain %q, got %q", expected, buf.String())
+		}
+	}
+	expectedDeps := []string{"docs/page.md", "docs/layout.md", "partials/cta.md", "partials/note.md", "layout.md"}
+	if diff := cmp.Diff(expectedDeps, page.(DependentPage).Dependencies()); diff != "" {
+		t.Errorf("Dependencies() mismatch (-want +got):\n%s", diff)
+	}
+
+	items, err := IndexDirectory(fsys, ".")
+	if err != nil {
+		t.Fatalf("IndexDirectory() failed: %v", err)
+	}
+	for _, item := range items {
+		if strings.HasPrefix(item.Path, PartialsDir) {
+			t.Errorf("expected %s not to be indexed", item.Path)
+		}
+	}
+}
+
+func TestLoadIncludeCycle(t *testing.T) {
+	reg := registry.New().RegisterLayout(registry.NewLayout("blog"))
+	fsys := fstest.MapFS{
+		"partials/a.md": {Data: []byte("```margo\n\\Include Path: \"partials/b.md\"\n```\n")},
+		"partials/b.md": {Data: []byte("```margo\n\\Include Path: \"partials/a.md\"\n```\n")},
+		"page.md":       {Data: []byte("---\nlayout: blog\n---\n```margo\n\\Include Path: \"partials/a.md\"\n```\n")},
+	}
+	_, err := NewLoader(fsys).Load(&FsItem{Path: "page.md", URL: "/page"}, reg)
+	var cycleErr *ErrIncludeCycle
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("expected an *ErrIncludeCycle, got %v", err)
+	}
+	if diff := cmp.Diff([]string{"partials/a.md", "partials/b.md", "partials/a.md"}, cycleErr.Chain); diff != "" {
+		t.Errorf("Chain mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadSlots(t *testing.T) {
+	layout := registry.NewLayout("docs")
+	layout.Register("Aside", func() templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			if _, err := io.WriteString(w, "<aside>"); err != nil {
+				return err
+			}
+			if err := templ.GetChildren(ctx).Render(ctx, w); err != nil {
+				return err
+			}
+			_, err := io.WriteString(w, "</aside>")
+			return err
+		})
+	})
+	reg := registry.New().RegisterLayout(layout)
+	layoutFile := "```margo\n\\Slot Name: \"hero\"\n    Default hero\n\\Aside\n    \\Slot Name: \"sidebar\"\n\\Slot\n\\Slot Name: \"footer\"\n```\n"
+
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+		err      string
+	}{
+		{
+			name: "fill and front-matter",
+			content: "---\nlayout: docs\nslots:\n  footer: \"*Footer*\"\n---\n# Body\n\n" +
+				"```margo\n\\Fill Name: \"sidebar\"\n    Sidebar for {{ page.url }}\n```\n",
+			expected: []string{"<p>Default hero</p>", "<aside><p>Sidebar for /docs/page</p>\n</aside>", "<h1", "<em>Footer</em>"},
+		},
+		{
+			name:    "unknown slot",
+			content: "---\nlayout: docs\n---\n```margo\n\\Fill Name: \"sidbar\"\n    Sidebar\n```\n",
+			err:     `unknown slot "sidbar". Must be one of the following: hero, sidebar, footer`,
+		},
+		{
+			name:    "filled twice",
+			content: "---\nlayout: docs\nslots:\n  footer: Footer\n---\n```margo\n\\Fill Name: \"footer\"\n    Footer\n```\n",
+			err:     `slot "footer" is filled twice, by the front-matter and a \Fill`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"docs/layout.md": {Data: []byte(layoutFile)},
+				"docs/page.md":   {Data: []byte(tt.content)},
+			}
+			item := &FsItem{Path: "docs/page.md", Layout: "docs/layout.md", URL: "/docs/page"}
+			page, err := NewLoader(fsys).Load(item, reg)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			var buf bytes.Buffer
+			ctx := types.WithPageCtx(context.Background(), &types.PageContext{URL: &url.URL{Path: "/docs/page"}})
+			if err := page.Render(ctx, &buf); err != nil {
+				t.Fatalf("Render() failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(buf.String(), expected) {
+					t.Errorf("expected output to contain %q, got %q", expected, buf.String())
+				}
+			}
+			if strings.Count(buf.String(), "Sidebar for") > 1 {
+				t.Errorf("expected the fill to be left out of the page, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestLoadLayoutFiles(t *testing.T) {
+	reg := registry.New().RegisterLayout(registry.NewLayout("docs"))
+	layoutFile := func(name string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(name + " start\n\n```margo\n\\Slot\n```\n\n" + name + " end\n")}
+	}
+	fsys := fstest.MapFS{
+		"layout.md":           layoutFile("root"),
+		"docs/layout.md":      layoutFile("docs"),
+		"docs/api/layout.md":  layoutFile("api"),
+		"docs/api/page.md":    {Data: []byte("---\nlayout: docs\n---\nPage\n")},
+		"docs/guide/page.md":  {Data: []byte("---\nlayout: docs\n---\nPage\n")},
+		"docs/bare.md":        {Data: []byte("---\nlayout: docs\nlayout_file: false\n---\nPage\n")},
+		"docs/landing.md":     {Data: []byte("---\nlayout: docs\nlayout_file: partials/landing.md\n---\nPage\n")},
+		"partials/landing.md": {Data: []byte("---\nlayout_file: false\n---\n" + string(layoutFile("landing").Data))},
+		"loop/layout.md":      {Data: []byte("---\nlayout_file: loop/page.md\n---\n```margo\n\\Slot\n```\n")},
+		"loop/page.md":        {Data: []byte("---\nlayout: docs\n---\nPage\n")},
+	}
+	items, err := IndexDirectory(fsys, ".")
+	if err != nil {
+		t.Fatalf("IndexDirectory() failed: %v", err)
+	}
+	byPath := make(map[string]*FsItem)
+	for _, item := range items {
+		byPath[item.Path] = item
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+		deps     []string
+	}{
+		{
+			path:     "docs/api/page.md",
+			expected: "root start docs start api start Page api end docs end root end",
+			deps:     []string{"docs/api/page.md", "docs/api/layout.md", "docs/layout.md", "layout.md"},
+		},
+		{
+			path:     "docs/guide/page.md",
+			expected: "root start docs start Page docs end root end",
+			deps:     []string{"docs/guide/page.md", "docs/layout.md", "layout.md", "docs/guide/layout.md"},
+		},
+		{
+			path:     "docs/bare.md",
+			expected: "Page",
+			deps:     []string{"docs/bare.md"},
+		},
+		{
+			path:     "docs/landing.md",
+			expected: "landing start Page landing end",
+			deps:     []string{"docs/landing.md", "partials/landing.md"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			page, err := NewLoader(fsys).Load(byPath[tt.path], reg)
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := page.Render(context.Background(), &buf); err != nil {
+				t.Fatalf("Render() failed: %v", err)
+			}
+			text := strings.Join(strings.Fields(strings.NewReplacer("<p>", "", "</p>", "").Replace(buf.String())), " ")
+			if text != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, text)
+			}
+			if diff := cmp.Diff(tt.deps, page.(DependentPage).Dependencies()); diff != "" {
+				t.Errorf("Dependencies() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	_, err = NewLoader(fsys).Load(byPath["loop/page.md"], reg)
+	var cycleErr *ErrLayoutCycle
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("expected an *ErrLayoutCycle, got %v", err)
+	}
+	if diff := cmp.Diff([]string{"loop/page.md", "loop/layout.md", "loop/page.md"}, cycleErr.Chain); diff != "" {
+		t.Errorf("Chain mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// countingFS counts the files opened by name.
+type countingFS struct {
+	fs.FS
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.mu.Lock()
+	c.counts[name]++
+	c.mu.Unlock()
+	return c.FS.Open(name)
+}
+
+func TestLoaderSharedFiles(t *testing.T) {
+	reg := registry.New().RegisterLayout(registry.NewLayout("blog"))
+	fsys := &countingFS{
+		FS: fstest.MapFS{
+			"data/team.yaml":  {Data: []byte("- name: Jane\n")},
+			"partials/cta.md": {Data: []byte("```margo\n\\Define Name: \"CTA\"\n    Join us\n```\n")},
+			"a.md":            {Data: []byte("---\nlayout: blog\n---\n```margo\n\\CTA\n```\n")},
+			"b.md":            {Data: []byte("---\nlayout: blog\n---\n```margo\n\\CTA\n```\n")},
+		},
+		counts: map[string]int{},
+	}
+	loader := NewLoader(fsys)
+	for _, path := range []string{"a.md", "b.md"} {
+		page, err := loader.Load(&FsItem{Path: path, URL: "/" + path}, reg)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		if _, ok := page.(DataPage).Data()["team"]; !ok {
+			t.Errorf("expected %s to have the team data", path)
+		}
+		var buf bytes.Buffer
+		if err := page.Render(context.Background(), &buf); err != nil {
+			t.Fatalf("Render() failed: %v", err)
+		}
+		if !strings.Contains(buf.String(), "Join us") {
+			t.Errorf("expected %s to render the CTA fragment, got %q", path, buf.String())
+		}
+	}
+	for _, file := range []string{"data/team.yaml", "partials/cta.md"} {
+		if count := fsys.counts[file]; count != 1 {
+			t.Errorf("expected %s to be read once, got %d", file, count)
+		}
+	}
+}
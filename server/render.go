@@ -0,0 +1,26 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/a-h/templ"
+
+	"github.com/iota-uz/margo/layouts"
+	"github.com/iota-uz/margo/types"
+)
+
+// RenderPage renders the page wrapped in layouts.Base.
+// The page context is made available to every component via types.UsePageCtx.
+// A panic while rendering is returned as an error.
+func RenderPage(ctx context.Context, w io.Writer, page Page, pageCtx *types.PageContext) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while rendering %s: %v", page.Path(), r)
+		}
+	}()
+	ctx = types.WithPageCtx(ctx, pageCtx)
+	ctx = templ.WithChildren(ctx, page)
+	return layouts.Base().Render(ctx, w)
+}
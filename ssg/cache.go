@@ -0,0 +1,187 @@
+package ssg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/iota-uz/margo/server"
+)
+
+// CacheFile is the name of the build cache stored in the destination directory.
+var CacheFile = ".margo-cache.json"
+
+// cacheVersion must be bumped whenever the cache format changes.
+const cacheVersion = 2
+
+// cacheEntry describes the output generated from a single source file.
+// Deps may hold files that did not exist at build time, creating them rebuilds the output.
+// Dirs are the directories whose files, existing or not yet created, the output depends on.
+type cacheEntry struct {
+	Output string   `json:"output"`
+	Deps   []string `json:"deps"`
+	Dirs   []string `json:"dirs,omitempty"`
+}
+
+// buildCache is the dependency graph of the last build.
+// It maps every source file to its output and the files the output was built from,
+// together with the content hash of every source file at build time.
+type buildCache struct {
+	Version int                    `json:"version"`
+	Hashes  map[string]string      `json:"hashes"`
+	Entries map[string]*cacheEntry `json:"entries"`
+
+	mu sync.Mutex
+	// changedDirs memoizes dirChanged for the hashes given to stale.
+	changedDirs map[string]bool
+}
+
+func newBuildCache(hashes map[string]string) *buildCache {
+	return &buildCache{
+		Version: cacheVersion,
+		Hashes:  hashes,
+		Entries: make(map[string]*cacheEntry),
+	}
+}
+
+// loadBuildCache reads the cache from dest.
+// A missing or outdated cache is not an error, nil is returned instead.
+func loadBuildCache(dest string) (*buildCache, error) {
+	b, err := os.ReadFile(filepath.Join(dest, CacheFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cache := &buildCache{}
+	if err := json.Unmarshal(b, cache); err != nil || cache.Version != cacheVersion {
+		return nil, nil
+	}
+	if cache.Entries == nil {
+		cache.Entries = make(map[string]*cacheEntry)
+	}
+	return cache, nil
+}
+
+func (c *buildCache) save(dest string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dest, CacheFile), b, 0o644)
+}
+
+func (c *buildCache) set(source string, entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Entries[source] = entry
+}
+
+// stale reports whether item has to be generated again given the current source hashes.
+func (c *buildCache) stale(item *server.FsItem, hashes map[string]string) bool {
+	entry, ok := c.Entries[item.Path]
+	if !ok {
+		return true
+	}
+	if _, err := os.Stat(entry.Output); err != nil {
+		return true
+	}
+	if item.Layout != "" && !slices.Contains(entry.Deps, item.Layout) {
+		return true
+	}
+	for _, dep := range entry.Deps {
+		// a dependency created, changed or removed since the build
+		if hashes[dep] != c.Hashes[dep] {
+			return true
+		}
+	}
+	for _, dir := range entry.Dirs {
+		if c.dirChanged(dir, hashes) {
+			return true
+		}
+	}
+	return false
+}
+
+// dirChanged reports whether a file of dir, or of its subdirectories, was created,
+// changed or removed since the build given the current source hashes.
+func (c *buildCache) dirChanged(dir string, hashes map[string]string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if changed, ok := c.changedDirs[dir]; ok {
+		return changed
+	}
+	prefix := filepath.FromSlash(dir) + string(filepath.Separator)
+	changed := false
+	for _, files := range []map[string]string{hashes, c.Hashes} {
+		for path := range files {
+			if strings.HasPrefix(path, prefix) && hashes[path] != c.Hashes[path] {
+				changed = true
+				break
+			}
+		}
+	}
+	if c.changedDirs == nil {
+		c.changedDirs = make(map[string]bool)
+	}
+	c.changedDirs[dir] = changed
+	return changed
+}
+
+// removed returns the entries whose source file no longer exists.
+func (c *buildCache) removed(items []*server.FsItem) []string {
+	sources := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		sources[item.Path] = struct{}{}
+	}
+	var res []string
+	for source := range c.Entries {
+		if _, ok := sources[source]; !ok {
+			res = append(res, source)
+		}
+	}
+	return res
+}
+
+// hashSources computes the sha256 of every file in src keyed by its relative path.
+func hashSources(src string) (map[string]string, error) {
+	fsys := os.DirFS(src)
+	hashes := make(map[string]string)
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		f, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		hashes[filepath.FromSlash(path)] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
@@ -0,0 +1,269 @@
+package ssg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/iota-uz/margo/parser"
+)
+
+// ReloadPath is the Server-Sent Events endpoint the injected script listens to.
+const ReloadPath = "/_margo/reload"
+
+const reloadScript = `<script>
+(function () {
+	var overlayId = "margo-error-overlay";
+	function hideOverlay() {
+		var el = document.getElementById(overlayId);
+		if (el) el.remove();
+	}
+	function showOverlay(errors) {
+		hideOverlay();
+		var el = document.createElement("div");
+		el.id = overlayId;
+		el.style.cssText = "position:fixed;inset:0;z-index:2147483647;overflow:auto;padding:32px;" +
+			"background:rgba(24,24,27,.95);color:#fafafa;font:14px/1.5 ui-monospace,monospace";
+		var title = document.createElement("h2");
+		title.textContent = "Build failed";
+		title.style.cssText = "margin:0 0 16px;color:#f87171";
+		el.appendChild(title);
+		errors.forEach(function (e) {
+			var pre = document.createElement("pre");
+			pre.style.cssText = "white-space:pre-wrap;margin:0 0 16px";
+			pre.textContent = e.path ? e.path + "\n" + e.message : e.message;
+			el.appendChild(pre);
+		});
+		document.body.appendChild(el);
+	}
+	var source = new EventSource("` + ReloadPath + `");
+	source.addEventListener("reload", function () { location.reload(); });
+	source.addEventListener("build-error", function (e) { showOverlay(JSON.parse(e.data)); });
+})();
+</script>`
+
+type devEvent struct {
+	name string
+	data string
+}
+
+type devError struct {
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+// buildErrors flattens joined errors, keeping the page path of every GenerationError.
+func buildErrors(err error) []devError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var res []devError
+		for _, e := range joined.Unwrap() {
+			res = append(res, buildErrors(e)...)
+		}
+		return res
+	}
+	var genErr *GenerationError
+	if errors.As(err, &genErr) {
+		return []devError{{Path: genErr.Path, Message: errorMessage(genErr.Err)}}
+	}
+	return []devError{{Message: errorMessage(err)}}
+}
+
+// errorMessage shows syntax errors with an excerpt of the offending source.
+func errorMessage(err error) string {
+	var diags parser.Diagnostics
+	if errors.As(err, &diags) {
+		return diags.Pretty()
+	}
+	return err.Error()
+}
+
+// DevServer serves the generated site, injects a live-reload script into every
+// HTML page and notifies connected browsers after each build.
+type DevServer struct {
+	dest    string
+	mu      sync.Mutex
+	clients map[chan devEvent]struct{}
+	failure *devEvent
+}
+
+func NewDevServer(dest string) *DevServer {
+	return &DevServer{
+		dest:    dest,
+		clients: make(map[chan devEvent]struct{}),
+	}
+}
+
+// Notify reports the result of a build to every connected browser.
+// A nil error reloads the page, otherwise an error overlay is shown.
+func (s *DevServer) Notify(err error) {
+	ev := devEvent{name: "reload"}
+	if err != nil {
+		data, jsonErr := json.Marshal(buildErrors(err))
+		if jsonErr != nil {
+			log.Println(jsonErr)
+			return
+		}
+		ev = devEvent{name: "build-error", data: string(data)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failure = &ev
+	} else {
+		s.failure = nil
+	}
+	for ch := range s.clients {
+		// the latest event wins over one the client has not consumed yet,
+		// so that a reload following a build error is never lost
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- ev
+	}
+}
+
+func (s *DevServer) subscribe() chan devEvent {
+	ch := make(chan devEvent, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[ch] = struct{}{}
+	if s.failure != nil {
+		ch <- *s.failure
+	}
+	return ch
+}
+
+func (s *DevServer) unsubscribe(ch chan devEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, ch)
+}
+
+func (s *DevServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == ReloadPath {
+		s.serveEvents(w, r)
+		return
+	}
+	file, ok := s.resolve(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if filepath.Ext(file) != ".html" {
+		http.ServeFile(w, r, file)
+		return
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := w.Write(injectReloadScript(content)); err != nil {
+		log.Println(err)
+	}
+}
+
+// resolve maps a URL to a generated file.
+// Ex.: "/docs/intro" -> "docs/intro.html"
+// Ex.: "/docs" -> "docs/index.html"
+// The build cache is not served.
+func (s *DevServer) resolve(urlPath string) (string, bool) {
+	p := filepath.Join(s.dest, filepath.FromSlash(path.Clean("/"+urlPath)))
+	cache := filepath.Join(s.dest, CacheFile)
+	for _, candidate := range []string{p, p + ".html", filepath.Join(p, "index.html")} {
+		if candidate == cache {
+			continue
+		}
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+func (s *DevServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// subscribe before answering, so that no build ends unnoticed in between
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, ev.data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func injectReloadScript(content []byte) []byte {
+	i := bytes.LastIndex(content, []byte("</body>"))
+	if i == -1 {
+		return append(content, reloadScript...)
+	}
+	res := make([]byte, 0, len(content)+len(reloadScript))
+	res = append(res, content[:i]...)
+	res = append(res, reloadScript...)
+	return append(res, content[i:]...)
+}
+
+// Dev generates the site, serves it on addr and regenerates it on every change,
+// reloading connected browsers after each successful build and showing an error
+// overlay after a failed one.
+func Dev(addr string, opts WatchOptions) error {
+	dev := NewDevServer(opts.DestinationDir)
+	onBuild := opts.OnBuild
+	opts.OnBuild = func(err error) {
+		if onBuild != nil {
+			onBuild(err)
+		}
+		dev.Notify(err)
+	}
+
+	err := Generate(
+		context.Background(),
+		opts.SourceDir,
+		opts.DestinationDir,
+		opts.Registry,
+		WithConcurrency(opts.Concurrency),
+		WithSite(opts.Site),
+	)
+	if err != nil {
+		log.Println(err)
+	}
+	opts.OnBuild(err)
+
+	errCh := make(chan error, 2)
+	go func() {
+		errCh <- Watch(opts)
+	}()
+	go func() {
+		log.Printf("Serving %s on http://%s\n", opts.DestinationDir, addr)
+		errCh <- http.ListenAndServe(addr, dev)
+	}()
+	return <-errCh
+}
@@ -0,0 +1,154 @@
+package ssg
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInjectReloadScript(t *testing.T) {
+	got := string(injectReloadScript([]byte("<html><body><p>Hi</p></body></html>")))
+	if !strings.HasSuffix(got, reloadScript+"</body></html>") {
+		t.Errorf("expected the script before </body>, got %q", got)
+	}
+	got = string(injectReloadScript([]byte("<p>Hi</p>")))
+	if got != "<p>Hi</p>"+reloadScript {
+		t.Errorf("expected the script to be appended, got %q", got)
+	}
+}
+
+func TestDevServerResolve(t *testing.T) {
+	dest := t.TempDir()
+	writeFile(t, filepath.Join(dest, "index.html"), "Home")
+	writeFile(t, filepath.Join(dest, "docs", "index.html"), "Docs")
+	writeFile(t, filepath.Join(dest, "docs", "intro.html"), "Intro")
+	writeFile(t, filepath.Join(dest, "styles.css"), "body {}")
+	writeFile(t, filepath.Join(dest, CacheFile), "{}")
+	dev := NewDevServer(dest)
+
+	tests := map[string]string{
+		"/":             "index.html",
+		"/docs":         filepath.Join("docs", "index.html"),
+		"/docs/intro":   filepath.Join("docs", "intro.html"),
+		"/styles.css":   "styles.css",
+		"/../index":     "index.html",
+		"/missing":      "",
+		"/" + CacheFile: "",
+		"/docs/intro/":  filepath.Join("docs", "intro.html"),
+	}
+	for urlPath, expected := range tests {
+		file, ok := dev.resolve(urlPath)
+		if expected == "" {
+			if ok {
+				t.Errorf("expected %s not to resolve, got %s", urlPath, file)
+			}
+			continue
+		}
+		if !ok || file != filepath.Join(dest, expected) {
+			t.Errorf("expected %s to resolve to %s, got %q", urlPath, expected, file)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	dev.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/intro", nil))
+	if body := rec.Body.String(); !strings.HasPrefix(body, "Intro") || !strings.Contains(body, ReloadPath) {
+		t.Errorf("expected the page with the reload script, got %q", body)
+	}
+	rec = httptest.NewRecorder()
+	dev.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/styles.css", nil))
+	if body := rec.Body.String(); body != "body {}" {
+		t.Errorf("expected the stylesheet as is, got %q", body)
+	}
+}
+
+// readEvent reads the next Server-Sent Event of r.
+func readEvent(t *testing.T, r *bufio.Reader) (name, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestDevServerEvents(t *testing.T) {
+	dev := NewDevServer(t.TempDir())
+	srv := httptest.NewServer(dev)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+ReloadPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+	body := bufio.NewReader(res.Body)
+
+	dev.Notify(&GenerationError{Path: "index.md", Err: errors.New("boom")})
+	name, data := readEvent(t, body)
+	if name != "build-error" || !strings.Contains(data, `"path":"index.md"`) || !strings.Contains(data, "boom") {
+		t.Errorf("expected a build-error event for index.md, got %s %s", name, data)
+	}
+	dev.Notify(nil)
+	if name, _ := readEvent(t, body); name != "reload" {
+		t.Errorf("expected a reload event, got %s", name)
+	}
+
+	// a browser connecting after a failed build gets the error right away
+	dev.Notify(errors.New("boom"))
+	late := dev.subscribe()
+	defer dev.unsubscribe(late)
+	if ev := <-late; ev.name != "build-error" {
+		t.Errorf("expected a build-error event, got %s", ev.name)
+	}
+	cancel()
+	_, _ = io.Copy(io.Discard, res.Body)
+}
+
+func TestDevServerSlowClient(t *testing.T) {
+	dev := NewDevServer(t.TempDir())
+	ch := dev.subscribe()
+	defer dev.unsubscribe(ch)
+
+	// the client consumes nothing while the site fails and is fixed
+	dev.Notify(errors.New("boom"))
+	dev.Notify(nil)
+	select {
+	case ev := <-ch:
+		if ev.name != "reload" {
+			t.Errorf("expected the latest event to be a reload, got %s", ev.name)
+		}
+	default:
+		t.Fatal("expected a pending event")
+	}
+	select {
+	case ev := <-ch:
+		t.Errorf("expected a single pending event, got %s", ev.name)
+	default:
+	}
+}
@@ -2,18 +2,16 @@ package ssg
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/iota-uz/margo/seo"
-	"log"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 
-	"github.com/a-h/templ"
-
-	"github.com/iota-uz/margo/layouts"
 	"github.com/iota-uz/margo/registry"
 	"github.com/iota-uz/margo/server"
 	"github.com/iota-uz/margo/types"
@@ -29,40 +27,71 @@ func (e *GenerationError) Error() string {
 	return fmt.Sprintf("error generating %s: %v", e.Path, e.Err)
 }
 
-func newGenerator(src, dest string, reg registry.Registry) *generator {
-	return &generator{
+func (e *GenerationError) Unwrap() error {
+	return e.Err
+}
+
+// Option configures the generation.
+type Option func(*generator)
+
+// WithConcurrency limits the number of items generated at the same time.
+// Values below 1 fall back to the number of CPUs.
+func WithConcurrency(n int) Option {
+	return func(g *generator) {
+		g.concurrency = n
+	}
+}
+
+// WithSite sets the site configuration, available as {{ site.* }} in margo blocks.
+// Changes to it are not tracked by GenerateIncremental.
+func WithSite(site map[string]any) Option {
+	return func(g *generator) {
+		g.site = site
+	}
+}
+
+func newGenerator(src, dest string, reg registry.Registry, opts ...Option) *generator {
+	g := &generator{
 		loader:   server.NewLoader(os.DirFS(src)),
 		src:      src,
 		dest:     dest,
 		registry: reg,
 	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.concurrency < 1 {
+		g.concurrency = runtime.NumCPU()
+	}
+	return g
 }
 
 type generator struct {
-	registry registry.Registry
-	loader   *server.MarkdownLoader
-	src      string
-	dest     string
+	registry    registry.Registry
+	loader      *server.MarkdownLoader
+	src         string
+	dest        string
+	concurrency int
+	site        map[string]any
 }
 
 func (g *generator) RenderPage(ctx context.Context, page server.Page) (string, error) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("could not render page %s: %v", page.Path(), r)
-		}
-	}()
 	u, err := url.Parse(page.URL())
 	if err != nil {
 		return "", err
 	}
-	ctx = types.WithPageCtx(ctx, &types.PageContext{
+	pageCtx := &types.PageContext{
 		URL:    u,
 		Locale: "en",
 		Seo:    seo.FromPageMeta(page.Meta()),
-	})
-	ctx = templ.WithChildren(ctx, page)
+		Meta:   page.Meta(),
+		Site:   g.site,
+	}
+	if dataPage, ok := page.(server.DataPage); ok {
+		pageCtx.Data = dataPage.Data()
+	}
 	var b strings.Builder
-	if err := layouts.Base().Render(ctx, &b); err != nil {
+	if err := server.RenderPage(ctx, &b, page, pageCtx); err != nil {
 		return "", err
 	}
 	return b.String(), nil
@@ -73,79 +102,104 @@ func (g *generator) processItem(
 	ctx context.Context,
 	item *server.FsItem,
 	dest string,
-	errCh chan<- error,
-	wg *sync.WaitGroup,
-) {
-	defer wg.Done()
+	cache *buildCache,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if item.IsStatic {
 		destFile := filepath.Join(dest, item.Path)
 		if err := os.MkdirAll(filepath.Dir(destFile), os.ModePerm); err != nil {
-			errCh <- &GenerationError{Path: destFile, Err: err}
-			return
+			return &GenerationError{Path: destFile, Err: err}
 		}
 		if err := CopyFile(filepath.Join(g.src, item.Path), destFile); err != nil {
-			errCh <- &GenerationError{Path: item.Path, Err: fmt.Errorf("failed to copy file: %w", err)}
-			return
+			return &GenerationError{Path: item.Path, Err: fmt.Errorf("failed to copy file: %w", err)}
 		}
-	} else {
-		page, err := g.loader.Load(item, g.registry)
-		if err != nil {
-			errCh <- &GenerationError{Path: item.Path, Err: err}
-			return
-		}
-		content, err := g.RenderPage(ctx, page)
-		if err != nil {
-			errCh <- &GenerationError{Path: item.Path, Err: err}
-			return
-		}
-		destFile := filepath.Join(dest, filepath.Dir(page.Path()), page.Name()+".html")
-		if err := os.MkdirAll(filepath.Dir(destFile), os.ModePerm); err != nil {
-			errCh <- &GenerationError{Path: destFile, Err: err}
-			return
-		}
-		if err := os.WriteFile(destFile, []byte(content), os.ModePerm); err != nil {
-			errCh <- &GenerationError{Path: destFile, Err: err}
-			return
+		cache.set(item.Path, &cacheEntry{Output: destFile, Deps: []string{item.Path}})
+		return nil
+	}
+	page, err := g.loader.Load(item, g.registry)
+	if err != nil {
+		return &GenerationError{Path: item.Path, Err: err}
+	}
+	content, err := g.RenderPage(ctx, page)
+	if err != nil {
+		return &GenerationError{Path: item.Path, Err: err}
+	}
+	destFile := filepath.Join(dest, filepath.Dir(page.Path()), page.Name()+".html")
+	if err := os.MkdirAll(filepath.Dir(destFile), os.ModePerm); err != nil {
+		return &GenerationError{Path: destFile, Err: err}
+	}
+	if err := os.WriteFile(destFile, []byte(content), os.ModePerm); err != nil {
+		return &GenerationError{Path: destFile, Err: err}
+	}
+	entry := &cacheEntry{Output: destFile, Deps: []string{item.Path}}
+	if dependent, ok := page.(server.DependentPage); ok {
+		entry.Deps = dependent.Dependencies()
+		entry.Dirs = dependent.DependencyDirs()
+	}
+	cache.set(item.Path, entry)
+	return nil
+}
+
+// removeOutputs deletes the outputs of sources that no longer exist.
+func (g *generator) removeOutputs(sources []string, cache *buildCache) error {
+	for _, source := range sources {
+		entry := cache.Entries[source]
+		if err := os.Remove(entry.Output); err != nil && !os.IsNotExist(err) {
+			return &GenerationError{Path: entry.Output, Err: err}
 		}
+		delete(cache.Entries, source)
 	}
+	return nil
 }
 
-// Generate processes all items concurrently
-func (g *generator) Generate(dest string, items []*server.FsItem) error {
+// Generate processes all items through a pool of g.concurrency workers,
+// recording every output in the cache. It stops handing out items as soon as
+// ctx is cancelled. Errors of all items are joined, each one being a *GenerationError.
+func (g *generator) Generate(ctx context.Context, dest string, items []*server.FsItem, cache *buildCache) error {
 	if len(items) == 0 {
 		return nil
 	}
 
-	// Create error channel and WaitGroup
+	itemCh := make(chan *server.FsItem)
 	errCh := make(chan error, len(items))
 	var wg sync.WaitGroup
 
-	// Process items concurrently
-	for _, item := range items {
+	for i := 0; i < min(g.concurrency, len(items)); i++ {
 		wg.Add(1)
-		go g.processItem(context.Background(), item, dest, errCh, &wg)
+		go func() {
+			defer wg.Done()
+			for item := range itemCh {
+				if err := g.processItem(ctx, item, dest, cache); err != nil {
+					errCh <- err
+				}
+			}
+		}()
 	}
 
-	// Wait for all goroutines to finish
-	go func() {
-		wg.Wait()
-		close(errCh)
-	}()
-
-	// Collect any errors
-	var errors []error
-	for err := range errCh {
-		errors = append(errors, err)
+dispatch:
+	for _, item := range items {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case itemCh <- item:
+		}
 	}
+	close(itemCh)
+	wg.Wait()
+	close(errCh)
 
-	if len(errors) > 0 {
-		// Combine all errors into a single error message
-		var errMsgs []string
-		for _, err := range errors {
-			errMsgs = append(errMsgs, err.Error())
+	var errs []error
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	for err := range errCh {
+		// items skipped after cancellation are already covered by ctx.Err()
+		if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			continue
 		}
-		return fmt.Errorf("generation errors:\n%s", strings.Join(errMsgs, "\n"))
+		errs = append(errs, err)
 	}
-
-	return nil
+	return errors.Join(errs...)
 }
@@ -1,6 +1,8 @@
 package ssg
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/iota-uz/margo/registry"
 	"github.com/iota-uz/margo/server"
@@ -17,6 +19,12 @@ type WatchOptions struct {
 	SourceDir      string
 	DestinationDir string
 	Registry       registry.Registry
+	// Concurrency limits the number of items generated at the same time, see WithConcurrency.
+	Concurrency int
+	// Site is the site configuration, see WithSite.
+	Site map[string]any
+	// OnBuild is called after every regeneration with its result.
+	OnBuild func(err error)
 }
 
 func countPages(items []*server.FsItem) int {
@@ -29,19 +37,84 @@ func countPages(items []*server.FsItem) int {
 	return count
 }
 
-func Generate(src, dest string, reg registry.Registry) error {
+// Generate renders every page of src into dest.
+// Cancelling ctx stops the generation, see Option for tuning it.
+func Generate(ctx context.Context, src, dest string, reg registry.Registry, opts ...Option) error {
 	start := time.Now()
 	items, err := server.IndexDirectory(os.DirFS(src), ".")
 	if err != nil {
 		return fmt.Errorf("failed to load items: %w", err)
 	}
-	if err := newGenerator(src, dest, reg).Generate(dest, items); err != nil {
-		return err
+	hashes, err := hashSources(src)
+	if err != nil {
+		return fmt.Errorf("failed to hash sources: %w", err)
+	}
+	cache := newBuildCache(hashes)
+	genErr := newGenerator(src, dest, reg, opts...).Generate(ctx, dest, items, cache)
+	if err := cache.save(dest); err != nil {
+		log.Println("failed to save build cache:", err)
+	}
+	if genErr != nil {
+		return genErr
 	}
 	log.Printf("Generated %d pages in %v\n", countPages(items), time.Since(start))
 	return nil
 }
 
+// GenerateIncremental regenerates only the outputs whose sources changed since the
+// build recorded in the cache of dest and removes the outputs of deleted sources.
+// Falls back to Generate when there is no cache.
+// Changes to the registry are not tracked, run Generate after changing components.
+func GenerateIncremental(ctx context.Context, src, dest string, reg registry.Registry, opts ...Option) error {
+	prev, err := loadBuildCache(dest)
+	if err != nil {
+		return fmt.Errorf("failed to load build cache: %w", err)
+	}
+	if prev == nil {
+		return Generate(ctx, src, dest, reg, opts...)
+	}
+	start := time.Now()
+	items, err := server.IndexDirectory(os.DirFS(src), ".")
+	if err != nil {
+		return fmt.Errorf("failed to load items: %w", err)
+	}
+	hashes, err := hashSources(src)
+	if err != nil {
+		return fmt.Errorf("failed to hash sources: %w", err)
+	}
+
+	g := newGenerator(src, dest, reg, opts...)
+	if err := g.removeOutputs(prev.removed(items), prev); err != nil {
+		return err
+	}
+	var stale []*server.FsItem
+	for _, item := range items {
+		if prev.stale(item, hashes) {
+			stale = append(stale, item)
+		}
+	}
+	cache := newBuildCache(hashes)
+	for source, entry := range prev.Entries {
+		cache.Entries[source] = entry
+	}
+	// failed items must not keep their previous entry, so they are retried on the next build
+	for _, item := range stale {
+		delete(cache.Entries, item.Path)
+	}
+
+	genErr := g.Generate(ctx, dest, stale, cache)
+	if err := cache.save(dest); err != nil {
+		log.Println("failed to save build cache:", err)
+	}
+	if genErr != nil {
+		return genErr
+	}
+	log.Printf("Regenerated %d of %d pages in %v\n", countPages(stale), countPages(items), time.Since(start))
+	return nil
+}
+
+// Watch regenerates the site on every change in opts.SourceDir.
+// A build still running when a newer change arrives is cancelled.
 func Watch(opts WatchOptions) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -70,6 +143,49 @@ func Watch(opts WatchOptions) error {
 	if err != nil {
 		return err
 	}
+
+	var (
+		cancel context.CancelFunc
+		done   chan struct{}
+	)
+	rebuild := func() {
+		if cancel != nil {
+			cancel()
+			// wait for the stale build to stop writing to the destination
+			<-done
+		}
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan struct{})
+		go func(done chan struct{}) {
+			defer close(done)
+			err := GenerateIncremental(
+				ctx,
+				opts.SourceDir,
+				opts.DestinationDir,
+				opts.Registry,
+				WithConcurrency(opts.Concurrency),
+				WithSite(opts.Site),
+			)
+			if errors.Is(err, context.Canceled) {
+				log.Println("Stale build cancelled")
+				return
+			}
+			if err != nil {
+				log.Println(err)
+			}
+			if opts.OnBuild != nil {
+				opts.OnBuild(err)
+			}
+		}(done)
+	}
+	defer func() {
+		if cancel != nil {
+			cancel()
+			<-done
+		}
+	}()
+
 	for {
 		select {
 		case event, ok := <-watcher.Events:
@@ -78,9 +194,7 @@ func Watch(opts WatchOptions) error {
 			}
 			if event.Has(fsnotify.Rename) || event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) {
 				log.Println("Regenerating...")
-				if err := Generate(opts.SourceDir, opts.DestinationDir, opts.Registry); err != nil {
-					log.Println(err)
-				}
+				rebuild()
 			}
 
 		case err, ok := <-watcher.Errors:
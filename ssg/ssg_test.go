@@ -0,0 +1,159 @@
+package ssg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iota-uz/margo/registry"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestGenerateIncremental(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+	reg := registry.New().RegisterLayout(registry.NewLayout("blog"))
+
+	writeFile(t, filepath.Join(src, "index.md"), "---\nlayout: blog\n---\nHome\n")
+	writeFile(t, filepath.Join(src, "docs", "a.md"), "---\nlayout: blog\n---\nPage A\n")
+	writeFile(t, filepath.Join(src, "docs", "b.md"), "---\nlayout: blog\n---\nPage B\n")
+	writeFile(t, filepath.Join(src, "docs", "layout.md"), "Docs\n\n```margo\n\\Slot\n```\n")
+
+	if err := Generate(context.Background(), src, dest, reg); err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+
+	// outputs that are not rebuilt keep the sentinel
+	writeFile(t, filepath.Join(dest, "index.html"), "sentinel")
+	writeFile(t, filepath.Join(dest, "docs", "b.html"), "sentinel")
+	writeFile(t, filepath.Join(src, "docs", "a.md"), "---\nlayout: blog\n---\nPage A changed\n")
+
+	if err := GenerateIncremental(context.Background(), src, dest, reg); err != nil {
+		t.Fatalf("GenerateIncremental() failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(dest, "docs", "a.html")); !strings.Contains(got, "Page A changed") {
+		t.Errorf("expected docs/a.html to be regenerated, got %q", got)
+	}
+	if got := readFile(t, filepath.Join(dest, "docs", "b.html")); got != "sentinel" {
+		t.Errorf("expected docs/b.html to be left untouched, got %q", got)
+	}
+
+	// a layout change rebuilds every page of its directory only
+	writeFile(t, filepath.Join(src, "docs", "layout.md"), "Docs v2\n\n```margo\n\\Slot\n```\n")
+	if err := GenerateIncremental(context.Background(), src, dest, reg); err != nil {
+		t.Fatalf("GenerateIncremental() failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(dest, "docs", "b.html")); !strings.Contains(got, "Docs v2") {
+		t.Errorf("expected docs/b.html to be regenerated, got %q", got)
+	}
+	if got := readFile(t, filepath.Join(dest, "index.html")); got != "sentinel" {
+		t.Errorf("expected index.html to be left untouched, got %q", got)
+	}
+
+	if err := os.Remove(filepath.Join(src, "docs", "a.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateIncremental(context.Background(), src, dest, reg); err != nil {
+		t.Fatalf("GenerateIncremental() failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "docs", "a.html")); !os.IsNotExist(err) {
+		t.Errorf("expected docs/a.html to be removed, got %v", err)
+	}
+}
+
+func TestGenerateIncrementalNewFiles(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+	reg := registry.New().RegisterLayout(registry.NewLayout("blog"))
+
+	writeFile(t, filepath.Join(src, "index.md"), "---\nlayout: blog\n---\n```margo\n\\If Cond: {{ data.banner.show }}\n    Banner\n```\n")
+	if err := Generate(context.Background(), src, dest, reg); err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(dest, "index.html")); strings.Contains(got, "Banner") {
+		t.Fatalf("expected no banner, got %q", got)
+	}
+
+	// a data file the page did not depend on before
+	writeFile(t, filepath.Join(src, "data", "banner.yaml"), "show: true\n")
+	if err := GenerateIncremental(context.Background(), src, dest, reg); err != nil {
+		t.Fatalf("GenerateIncremental() failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(dest, "index.html")); !strings.Contains(got, "Banner") {
+		t.Errorf("expected index.html to be regenerated with the banner, got %q", got)
+	}
+}
+
+func TestGenerateIncrementalAncestorLayout(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+	reg := registry.New().RegisterLayout(registry.NewLayout("blog"))
+
+	writeFile(t, filepath.Join(src, "docs", "api", "page.md"), "---\nlayout: blog\n---\nPage\n")
+	writeFile(t, filepath.Join(src, "docs", "api", "layout.md"), "API\n\n```margo\n\\Slot\n```\n")
+	if err := Generate(context.Background(), src, dest, reg); err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+
+	// a layout file created above the ones wrapping the page
+	writeFile(t, filepath.Join(src, "docs", "layout.md"), "Docs\n\n```margo\n\\Slot\n```\n")
+	if err := GenerateIncremental(context.Background(), src, dest, reg); err != nil {
+		t.Fatalf("GenerateIncremental() failed: %v", err)
+	}
+	got := readFile(t, filepath.Join(dest, "docs", "api", "page.html"))
+	for _, expected := range []string{"Docs", "API", "Page"} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected docs/api/page.html to contain %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+	reg := registry.New().RegisterLayout(registry.NewLayout("blog"))
+
+	writeFile(t, filepath.Join(src, "index.md"), "---\nlayout: blog\n---\nHome\n")
+	writeFile(t, filepath.Join(src, "a.md"), "---\nlayout: missing\n---\nA\n")
+	writeFile(t, filepath.Join(src, "b.md"), "Missing layout\n")
+
+	err := Generate(context.Background(), src, dest, reg, WithConcurrency(1))
+	if err == nil {
+		t.Fatal("expected Generate() to fail")
+	}
+	var genErr *GenerationError
+	if !errors.As(err, &genErr) {
+		t.Fatalf("expected a *GenerationError, got %T", err)
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("expected 2 joined errors, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Generate(ctx, src, t.TempDir(), reg); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package margo
+
+import (
+	"slices"
+	"strings"
+)
+
+// maxSuggestions is the number of names suggested for a misspelled one.
+const maxSuggestions = 3
+
+// suggest returns the candidates closest to name by edit distance, closest first.
+// Candidates further than a third of the length of name are left out,
+// and names are compared with normalize when it is not nil.
+func suggest(name string, candidates []string, normalize func(string) string) []string {
+	if normalize == nil {
+		normalize = strings.ToLower
+	}
+	target := normalize(name)
+	limit := max(1, len(target)/3)
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	for _, candidate := range candidates {
+		d := editDistance(target, normalize(candidate))
+		if d <= limit && !slices.ContainsFunc(matches, func(m match) bool { return m.name == candidate }) {
+			matches = append(matches, match{candidate, d})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int {
+		if a.distance != b.distance {
+			return a.distance - b.distance
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	var res []string
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		res = append(res, matches[i].name)
+	}
+	return res
+}
+
+// didYouMean formats suggestions as a hint. Ex.: "did you mean Title or Tile?"
+func didYouMean(suggestions []string) string {
+	switch len(suggestions) {
+	case 0:
+		return ""
+	case 1:
+		return "did you mean " + suggestions[0] + "?"
+	default:
+		last := len(suggestions) - 1
+		return "did you mean " + strings.Join(suggestions[:last], ", ") + " or " + suggestions[last] + "?"
+	}
+}
+
+// editDistance returns the number of single byte edits turning a into b,
+// swapping two adjacent bytes counting as one edit as in "Titel".
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
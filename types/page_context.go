@@ -47,4 +47,10 @@ type PageContext struct {
 	URL    *url.URL
 	Locale string
 	Seo    *seo.Meta
+	// Meta is the front-matter of the page, available as {{ page.* }} in margo blocks.
+	Meta map[string]any
+	// Site is the site configuration, available as {{ site.* }} in margo blocks.
+	Site map[string]any
+	// Data holds the data files of the site, available as {{ data.* }} in margo blocks.
+	Data map[string]any
 }
@@ -0,0 +1,187 @@
+package margo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/iota-uz/margo/parser"
+	"github.com/iota-uz/margo/types"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// errUnknownVariable is returned when an expression references a missing value.
+var errUnknownVariable = errors.New("unknown variable")
+
+// errUnknownRoot is returned when an expression starts with a name that is neither
+// page, site, data nor a variable set by withVar.
+var errUnknownRoot = errors.New("unknown variable")
+
+var varsKey = ContextKey{"vars"}
+
+// withVar makes value available to expressions as name, shadowing page, site and data.
+func withVar(ctx context.Context, name string, value any) context.Context {
+	vars, _ := ctx.Value(varsKey).(map[string]any)
+	scoped := make(map[string]any, len(vars)+1)
+	for k, v := range vars {
+		scoped[k] = v
+	}
+	scoped[name] = value
+	return context.WithValue(ctx, varsKey, scoped)
+}
+
+// resolveExpr looks up the variable referenced by expr.
+// Variables set by withVar come first, then page.* resolves to the front-matter of
+// the page, falling back to its url and locale, site.* to the site configuration
+// and data.* to the data files.
+func resolveExpr(ctx context.Context, expr *parser.Expr) (any, error) {
+	var v any
+	vars, _ := ctx.Value(varsKey).(map[string]any)
+	if scoped, ok := vars[expr.Path[0]]; ok {
+		v = scoped
+	} else {
+		pageCtx, err := types.UsePageCtx(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve %s: %w", expr, err)
+		}
+		v, err = pageVar(pageCtx, expr.Path[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, name := range expr.Path[1:] {
+		next, ok := lookupKey(v, name)
+		if !ok {
+			return nil, fmt.Errorf("%w %s", errUnknownVariable, strings.Join(expr.Path[:i+2], "."))
+		}
+		v = next
+	}
+	return normalizeValue(v), nil
+}
+
+// pageVar returns the root variable name of the page context.
+func pageVar(pageCtx *types.PageContext, name string) (any, error) {
+	switch name {
+	case "page":
+		page := map[string]any{
+			"url":    "",
+			"locale": pageCtx.Locale,
+		}
+		if pageCtx.URL != nil {
+			page["url"] = pageCtx.URL.Path
+		}
+		for k, v := range pageCtx.Meta {
+			page[k] = v
+		}
+		return page, nil
+	case "site":
+		return pageCtx.Site, nil
+	case "data":
+		return pageCtx.Data, nil
+	default:
+		// not errUnknownVariable, a misspelled root is never an optional value
+		return nil, fmt.Errorf("%w %s, expected page, site or data", errUnknownRoot, name)
+	}
+}
+
+// lookupKey returns the value of key in a map, matching the key case-insensitively
+// when there is no exact match.
+func lookupKey(v any, key string) (any, bool) {
+	if object, ok := v.(parser.Object); ok {
+		for _, attr := range object {
+			if strings.EqualFold(attr.Name, key) {
+				return attr.Value, true
+			}
+		}
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String && rv.Type().Key().Kind() != reflect.Interface {
+		return nil, false
+	}
+	var found reflect.Value
+	iter := rv.MapRange()
+	for iter.Next() {
+		k := fmt.Sprint(iter.Key().Interface())
+		if k == key {
+			return iter.Value().Interface(), true
+		}
+		if strings.EqualFold(k, key) {
+			found = iter.Value()
+		}
+	}
+	if !found.IsValid() {
+		return nil, false
+	}
+	return found.Interface(), true
+}
+
+// normalizeValue converts a value decoded from YAML to the types produced by
+// the margo parser, so that it binds to props like a literal.
+func normalizeValue(v any) any {
+	switch v.(type) {
+	case parser.List, parser.Object:
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Slice:
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+		list := make(parser.List, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			list = append(list, normalizeValue(rv.Index(i).Interface()))
+		}
+		return list
+	case reflect.Map:
+		object := make(parser.Object, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			object = append(object, &parser.Attribute{
+				Name:  fmt.Sprint(iter.Key().Interface()),
+				Value: normalizeValue(iter.Value().Interface()),
+			})
+		}
+		sort.Slice(object, func(i, j int) bool {
+			return object[i].Name < object[j].Name
+		})
+		return object
+	default:
+		return v
+	}
+}
+
+// interpolate replaces the expressions of s with their values.
+// Expressions with an unknown root, such as {{ name }} in a code sample, are kept as is.
+func interpolate(ctx context.Context, s string) (string, error) {
+	return parser.Interpolate(s, func(expr *parser.Expr) (any, error) {
+		v, err := resolveExpr(ctx, expr)
+		if errors.Is(err, errUnknownRoot) {
+			return nil, parser.SkipExpr
+		}
+		return v, err
+	})
+}
+
+// resolveValue resolves an expression or interpolates a string, other values are returned as is.
+// Raw strings are not interpolated.
+func resolveValue(ctx context.Context, v any) (any, error) {
+	switch v := v.(type) {
+	case *parser.Expr:
+		return resolveExpr(ctx, v)
+	case string:
+		return interpolate(ctx, v)
+	case parser.RawString:
+		return string(v), nil
+	default:
+		return v, nil
+	}
+}